@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zathras777/sensors/pkg/mdev"
+	"github.com/zathras777/sensors/pkg/zcan"
+)
+
+// runCommand runs the command given on the command line, returning false if
+// the arguments are not a command.
+func runCommand(args []string) bool {
+	if len(args) < 2 {
+		return false
+	}
+	switch args[0] {
+	case "modbus":
+		switch args[1] {
+		case "scan":
+			if err := modbusScan(args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return true
+		}
+	case "zcan":
+		switch args[1] {
+		case "rmi":
+			if err := zcanRMI(args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return true
+		case "replay":
+			if err := zcanReplay(args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// parseRange parses "1-247" or "20" into the first and last values.
+func parseRange(s string, limit int) (int, int, error) {
+	parts := strings.SplitN(s, "-", 2)
+	from, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range '%s'", s)
+	}
+	to := from
+	if len(parts) == 2 {
+		if to, err = strconv.Atoi(strings.TrimSpace(parts[1])); err != nil {
+			return 0, 0, fmt.Errorf("invalid range '%s'", s)
+		}
+	}
+	if from < 0 || to < from || to > limit {
+		return 0, 0, fmt.Errorf("invalid range '%s'", s)
+	}
+	return from, to, nil
+}
+
+// parseBauds parses a comma separated list of baud rates. An empty list is
+// returned for an empty string, so that the scan uses its default rates.
+func parseBauds(s string) ([]int, error) {
+	var bauds []int
+	if strings.TrimSpace(s) == "" {
+		return bauds, nil
+	}
+	for _, b := range strings.Split(s, ",") {
+		baud, err := strconv.Atoi(strings.TrimSpace(b))
+		if err != nil || baud <= 0 {
+			return nil, fmt.Errorf("invalid baud rate '%s'", b)
+		}
+		bauds = append(bauds, baud)
+	}
+	return bauds, nil
+}
+
+func modbusScan(args []string) error {
+	fs := flag.NewFlagSet("modbus scan", flag.ExitOnError)
+	device := fs.String("device", "", "serial device, e.g. /dev/ttyUSB0")
+	host := fs.String("host", "", "modbus TCP host as host:port")
+	bauds := fs.String("baud", "", "comma separated list of baud rates to try, 9600 and 19200 if not given")
+	slaves := fs.String("slaves", "1-247", "range of slave IDs to probe")
+	registers := fs.String("registers", "0-99", "range of registers to read")
+	types := fs.String("types", "holding,input", "register types to read")
+	timeout := fs.Int("timeout", 500, "response timeout in milliseconds")
+	yamlOut := fs.Bool("yaml", false, "output a registers block for the configuration file")
+	fs.Parse(args)
+
+	opts := mdev.ScanOptions{Device: *device, Host: *host, Timeout: time.Duration(*timeout) * time.Millisecond}
+	from, to, err := parseRange(*slaves, 247)
+	if err != nil {
+		return err
+	}
+	opts.SlaveFrom, opts.SlaveTo = byte(from), byte(to)
+	if from, to, err = parseRange(*registers, 65535); err != nil {
+		return err
+	}
+	opts.RegFrom, opts.RegTo = uint16(from), uint16(to)
+	if opts.Bauds, err = parseBauds(*bauds); err != nil {
+		return err
+	}
+	for _, typ := range strings.Split(*types, ",") {
+		switch strings.TrimSpace(strings.ToLower(typ)) {
+		case "holding":
+			opts.Holding = true
+		case "input":
+			opts.Input = true
+		default:
+			return fmt.Errorf("unknown register type '%s'", typ)
+		}
+	}
+
+	found, err := mdev.ScanSlaves(opts)
+	if err != nil {
+		return err
+	}
+	if len(found) == 0 {
+		return fmt.Errorf("no slaves responded")
+	}
+	for _, slave := range found {
+		results, err := mdev.ScanRegisters(opts, slave)
+		if err != nil {
+			return err
+		}
+		if *yamlOut {
+			fmt.Printf("  - name: slave%d\n    slaveid: %d\n", slave.SlaveID, slave.SlaveID)
+			if opts.Host == "" {
+				fmt.Printf("    baudrate: %d\n    device: %s\n", slave.Baud, opts.Device)
+			}
+			fmt.Print(mdev.ScanYAML(results))
+			continue
+		}
+		if opts.Host != "" {
+			fmt.Printf("slave %d\n", slave.SlaveID)
+		} else {
+			fmt.Printf("slave %d @ %d baud\n", slave.SlaveID, slave.Baud)
+		}
+		for _, res := range results {
+			typ := "holding"
+			if res.Type == mdev.ModbusInput {
+				typ = "input"
+			}
+			if res.Exception != 0 {
+				fmt.Printf("  %-7s %5d  exception %d\n", typ, res.Register, res.Exception)
+				continue
+			}
+			fmt.Printf("  %-7s %5d  0x%04x  %6d  %6d\n", typ, res.Register, res.Value, res.Value, int16(res.Value))
+		}
+	}
+	return nil
+}
+
+func zcanRMI(args []string) error {
+	fs := flag.NewFlagSet("zcan rmi", flag.ExitOnError)
+	iface := fs.String("interface", "can0", "CAN interface")
+	nodeID := fs.Int("nodeid", 0, "node id to use, 0 to choose a free one")
+	walk := fs.Bool("walk", false, "query every property in the -props range")
+	props := fs.String("props", "1-255", "range of properties to walk")
+	params := url.Values{}
+	for _, name := range []string{"node", "unit", "subunit", "prop", "type", "mode"} {
+		name := name
+		fs.Func(name, fmt.Sprintf("RMI %s parameter", name), func(s string) error {
+			params.Set(name, s)
+			return nil
+		})
+	}
+	fs.Parse(args)
+
+	if *walk {
+		from, to, err := parseRange(*props, 255)
+		if err != nil {
+			return err
+		}
+		params.Set("walk", "true")
+		params.Set("from", strconv.Itoa(from))
+		params.Set("to", strconv.Itoa(to))
+	}
+
+	zc := zcan.NewZehnderDevice(byte(*nodeID))
+	if *nodeID == 0 {
+		if err := zc.SetAutoNodeID(32, 63); err != nil {
+			return err
+		}
+	}
+	if err := zc.Connect(*iface); err != nil {
+		return err
+	}
+	defer zc.Disconnect()
+	if err := zc.Start(); err != nil {
+		return err
+	}
+	defer zc.Stop()
+
+	rv, err := rmiQuery(zc, params)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(rv, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+func zcanReplay(args []string) error {
+	fs := flag.NewFlagSet("zcan replay", flag.ExitOnError)
+	fn := fs.String("file", "", "candump log to replay")
+	speed := fs.Float64("speed", 0, "replay speed, e.g. 1 for the original timing, 0 for as fast as possible")
+	nodeID := fs.Int("nodeid", 0, "node id used when the capture was made, to decode RMI responses")
+	pdoFile := fs.String("pdofile", "", "additional PDO catalogue")
+	fs.Parse(args)
+	if *fn == "" {
+		return fmt.Errorf("a file to replay must be given")
+	}
+	if *pdoFile != "" {
+		if err := zcan.LoadPDOCatalogue(*pdoFile); err != nil {
+			return err
+		}
+	}
+
+	zc := zcan.NewZehnderDevice(byte(*nodeID))
+	zc.SetDefaultRMICallback(func(rmi *zcan.ZehnderRMI) {
+		fmt.Printf("RMI from %d to %d: % X\n", rmi.SourceId, rmi.DestId, rmi.Data[:rmi.DataLength])
+	})
+	if err := zc.Start(); err != nil {
+		return err
+	}
+	defer zc.Stop()
+	if err := zc.ReplayDumpFile(*fn, *speed); err != nil {
+		return err
+	}
+
+	out, err := json.MarshalIndent(map[string]interface{}{
+		"pdo":    zc.JsonResponse(),
+		"nodes":  zc.JsonNodes(),
+		"errors": zc.JsonErrors(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		s        string
+		limit    int
+		from, to int
+		ok       bool
+	}{
+		{"1-247", 247, 1, 247, true},
+		{"20", 247, 20, 20, true},
+		{" 5 - 10 ", 247, 5, 10, true},
+		{"0-0", 255, 0, 0, true},
+		{"10-5", 247, 0, 0, false},
+		{"1-248", 247, 0, 0, false},
+		{"-1", 247, 0, 0, false},
+		{"a-b", 247, 0, 0, false},
+		{"1-b", 247, 0, 0, false},
+		{"", 247, 0, 0, false},
+	}
+	for _, tc := range tests {
+		from, to, err := parseRange(tc.s, tc.limit)
+		if (err == nil) != tc.ok {
+			t.Errorf("'%s': error %v, expected ok %v", tc.s, err, tc.ok)
+			continue
+		}
+		if from != tc.from || to != tc.to {
+			t.Errorf("'%s': got %d-%d, expected %d-%d", tc.s, from, to, tc.from, tc.to)
+		}
+	}
+}
+
+func TestParseBauds(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []int
+		ok   bool
+	}{
+		{"", nil, true},
+		{"9600", []int{9600}, true},
+		{"9600, 19200,38400", []int{9600, 19200, 38400}, true},
+		{"9600,", nil, false},
+		{"fast", nil, false},
+		{"0", nil, false},
+	}
+	for _, tc := range tests {
+		bauds, err := parseBauds(tc.s)
+		if (err == nil) != tc.ok {
+			t.Errorf("'%s': error %v, expected ok %v", tc.s, err, tc.ok)
+			continue
+		}
+		if !reflect.DeepEqual(bauds, tc.want) {
+			t.Errorf("'%s': got %v, expected %v", tc.s, bauds, tc.want)
+		}
+	}
+}
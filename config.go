@@ -3,62 +3,241 @@ package main
 import (
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v2"
 )
 
+type FilterConfig struct {
+	Type       string
+	Window     int
+	Alpha      float64
+	MaxRate    float64
+	MaxRejects int
+	Min        *float64
+	Max        *float64
+	Band       float64
+}
+
+type CalibrationConfig struct {
+	Gain       *float64
+	Offset     float64
+	Polynomial []float64
+	Table      [][]float64
+}
+
+// ReadingOptions is the processing that can be configured for any reading.
+type ReadingOptions struct {
+	Calibration CalibrationConfig
+	Units       string
+	OutputUnits string
+	Filters     []FilterConfig
+}
+
 type ModbusRegister struct {
-	Description string
-	Tag         string
-	Typ         string
-	Register    uint16
-	Factor      uint16
-	Offset      int
+	Description    string
+	Tag            string
+	Typ            string
+	Register       uint16
+	Factor         int
+	Multiplier     float64
+	Offset         float64
+	Order          string
+	Length         uint16
+	Labels         map[int]string
+	Interval       int
+	Skip           bool
+	ReadingOptions `yaml:",inline"`
+}
+
+type ModbusRegisters struct {
+	Holding []ModbusRegister
+	Input   []ModbusRegister
 }
 
 type ModbusNode struct {
-	Name      string
-	SlaveId   byte
-	Baudrate  int
-	Device    string
-	Interval  int
-	Registers struct {
-		Holding []ModbusRegister
-		Input   []ModbusRegister
-	}
+	Name       string
+	Profile    string
+	SlaveId    byte
+	Baudrate   int
+	Device     string
+	Interval   int
+	FrameDelay int
+	Registers  ModbusRegisters
 }
 
 type Max6675Node struct {
+	Name           string
+	Path           string
+	Interval       int
+	Chip           string
+	Type           string
+	Averaging      int
+	Wires          int
+	Filter         int
+	RRef           float64
+	RNominal       float64
+	ReadingOptions `yaml:",inline"`
+}
+
+type SysfsAttribute struct {
+	Name           string
+	Chip           string
+	Path           string
+	Scale          float64
+	Offset         float64
+	ReadingOptions `yaml:",inline"`
+}
+
+type SysfsNode struct {
+	Name       string
+	Root       string
+	Interval   int
+	Attributes []SysfsAttribute
+}
+
+type W1Probe struct {
+	Id             string
+	Name           string
+	ReadingOptions `yaml:",inline"`
+}
+
+type W1Node struct {
+	Name         string
+	Path         string
+	Interval     int
+	AutoDiscover bool
+	Probes       []W1Probe
+}
+
+type ZcanPDO struct {
+	Slug           string
+	Interval       byte
+	ReadingOptions `yaml:",inline"`
+}
+
+// ZcanNodeID is either a fixed node id or "auto" to choose a free id.
+type ZcanNodeID struct {
+	Auto bool
+	ID   byte
+}
+
+func (zn *ZcanNodeID) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil && strings.ToLower(s) == "auto" {
+		zn.Auto = true
+		return nil
+	}
+	return unmarshal(&zn.ID)
+}
+
+type ZcanFilter struct {
+	Unit         byte
+	SubUnit      byte
+	IntervalProp byte
+	IntervalType string
+	ResetProp    byte
+	ResetValue   byte
+	HistoryFile  string
+}
+
+type ZcanErrorProp struct {
+	Prop     byte
 	Name     string
-	Path     string
+	Severity string
+	Message  string
+}
+
+type ZcanErrors struct {
+	SubUnit  byte
+	Props    []ZcanErrorProp
+	Type     string
 	Interval int
 }
 
-type ZcanPDO struct {
-	Slug     string
-	Interval byte
+type ZcanScheduleEntry struct {
+	At      string
+	Days    []string
+	Action  string
+	Value   string
+	Minutes int
 }
 
 type ZcanNode struct {
-	Name      string
-	Interface string
-	NodeId    byte
-	PDO       struct {
+	Name        string
+	Interface   string
+	NodeId      ZcanNodeID
+	NodeIdRange []byte
+	PDOFile     string
+	QueryNodes  bool
+	Capture     string
+	Filter      ZcanFilter
+	Errors      ZcanErrors
+	Schedule    []ZcanScheduleEntry
+	PDO         struct {
 		Node byte
 		PDO  []ZcanPDO
 	}
 }
 
+type AlertRule struct {
+	Name       string
+	Service    string
+	Reading    string
+	Condition  string
+	Threshold  float64
+	Hysteresis float64
+	For        int
+}
+
+type AlertNotify struct {
+	Type     string
+	Url      string
+	Command  string
+	Args     []string
+	Broker   string
+	Topic    string
+	ClientId string
+	Username string
+	Password string
+	Retain   bool
+}
+
+type AlertsNode struct {
+	Interval int
+	Rules    []AlertRule
+	Notify   []AlertNotify
+}
+
+type EnergyIntegrator struct {
+	Name    string
+	Service string
+	Reading string
+	Units   string
+}
+
+type EnergyNode struct {
+	Interval    int
+	StateFile   string
+	Integrators []EnergyIntegrator
+}
+
 type HttpNode struct {
 	Address string
 	Port    int
 }
 
 type ConfigFile struct {
-	Http    HttpNode
-	Zcan    []ZcanNode
-	Modbus  []ModbusNode
-	Max6675 []Max6675Node
+	Profiles string
+	Http     HttpNode
+	Zcan     []ZcanNode
+	Modbus   []ModbusNode
+	Max6675  []Max6675Node
+	Sysfs    []SysfsNode
+	W1       []W1Node
+	Alerts   AlertsNode
+	Energy   EnergyNode
 }
 
 var cfg ConfigFile
@@ -68,5 +247,21 @@ func processConfigurationFile(fn string) error {
 	if err != nil {
 		log.Fatal(err)
 	}
-	return yaml.Unmarshal(dat, &cfg)
+	if err = yaml.Unmarshal(dat, &cfg); err != nil {
+		return err
+	}
+
+	// profiles are looked for relative to the configuration file
+	if cfg.Profiles == "" {
+		cfg.Profiles = "profiles"
+	}
+	if !filepath.IsAbs(cfg.Profiles) {
+		cfg.Profiles = filepath.Join(filepath.Dir(fn), cfg.Profiles)
+	}
+	for n := range cfg.Modbus {
+		if err = applyModbusProfile(&cfg.Modbus[n], cfg.Profiles); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"sort"
 	"strings"
 )
@@ -14,18 +15,34 @@ type JsonEndpoint struct {
 	Handler  func() map[string]interface{}
 }
 
+// ActionEndpoint is an endpoint that takes parameters, from the query string
+// or a form body, and is only available using the given method.
+type ActionEndpoint struct {
+	Endpoint string
+	Method   string
+	Handler  func(params url.Values) (map[string]interface{}, error)
+}
+
 var endpoints []JsonEndpoint
+var actionEndpoints []ActionEndpoint
 var httpServer *http.Server
 
 func AddEndpoint(endp JsonEndpoint) {
 	endpoints = append(endpoints, endp)
 }
 
+func AddActionEndpoint(endp ActionEndpoint) {
+	actionEndpoints = append(actionEndpoints, endp)
+}
+
 func logAvailableEndpoints() {
 	var avail []string
 	for _, e := range endpoints {
 		avail = append(avail, e.Endpoint)
 	}
+	for _, e := range actionEndpoints {
+		avail = append(avail, fmt.Sprintf("%s (%s)", e.Endpoint, e.Method))
+	}
 	sort.Strings(avail)
 	log.Printf("available endpoints: %s", strings.Join(avail, ", "))
 }
@@ -48,10 +65,48 @@ func startHttpServer(host string, port int) error {
 
 var unknownURLs map[string]int = make(map[string]int)
 
+func writeJson(w http.ResponseWriter, status int, dataMap map[string]interface{}) {
+	outData, err := json.Marshal(dataMap)
+	if err != nil {
+		log.Printf("jsonResponse: Unable to generate json data: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(outData)
+}
+
+func actionResponse(w http.ResponseWriter, r *http.Request, endp ActionEndpoint) {
+	if r.Method != endp.Method {
+		w.Header().Set("Allow", endp.Method)
+		writeJson(w, http.StatusMethodNotAllowed, map[string]interface{}{"error": fmt.Sprintf("%s must be used", endp.Method)})
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+		return
+	}
+	dataMap, err := endp.Handler(r.Form)
+	if err != nil {
+		log.Printf("%s: %s", endp.Endpoint, err)
+		writeJson(w, http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+		return
+	}
+	writeJson(w, http.StatusOK, dataMap)
+}
+
 func jsonResponse(w http.ResponseWriter, r *http.Request) {
 	var dataMap map[string]interface{}
 	var found bool
 
+	for _, poss := range actionEndpoints {
+		if poss.Endpoint == r.URL.Path {
+			actionResponse(w, r, poss)
+			return
+		}
+	}
+
 	for _, poss := range endpoints {
 		if poss.Endpoint == r.RequestURI {
 			dataMap = poss.Handler()
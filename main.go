@@ -3,23 +3,43 @@ package main
 import (
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"os/signal"
 	"sort"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/zathras777/sensors/pkg/alerts"
+	"github.com/zathras777/sensors/pkg/energy"
 	"github.com/zathras777/sensors/pkg/max6675"
 	"github.com/zathras777/sensors/pkg/mdev"
+	"github.com/zathras777/sensors/pkg/reading"
+	"github.com/zathras777/sensors/pkg/sysfs"
+	"github.com/zathras777/sensors/pkg/w1"
 	"github.com/zathras777/sensors/pkg/zcan"
 )
 
 var setupZcan []*zcan.ZehnderDevice
+var setupVentilation []*ventilationControl
 var setupModbus []*mdev.ModbusDevice
 var setupMax6675 []*max6675.Max6675Device
+var setupSysfs []*sysfs.SysfsDevice
+var setupW1 []*w1.W1Device
+var alertManager *alerts.Manager
+var energyManager *energy.Manager
 
 func main() {
-	if err := processConfigurationFile("./config.yaml"); err != nil {
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
+	configFile := "./config.yaml"
+	if len(os.Args) > 1 {
+		configFile = os.Args[1]
+	}
+	if err := processConfigurationFile(configFile); err != nil {
 		log.Fatal(err)
 	}
 
@@ -35,10 +55,26 @@ func main() {
 		addMax6675(node)
 	}
 
-	if len(setupZcan)+len(setupModbus)+len(setupMax6675) == 0 {
+	for _, node := range cfg.Sysfs {
+		addSysfs(node)
+	}
+
+	for _, node := range cfg.W1 {
+		addW1(node)
+	}
+
+	if len(setupZcan)+len(setupModbus)+len(setupMax6675)+len(setupSysfs)+len(setupW1) == 0 {
 		log.Fatal("Unable to configure any services. Nothing to do? Exiting")
 	}
 
+	if len(cfg.Energy.Integrators) > 0 {
+		addEnergy(cfg.Energy)
+	}
+
+	if len(cfg.Alerts.Rules) > 0 || len(cfg.Alerts.Notify) > 0 {
+		addAlerts(cfg.Alerts)
+	}
+
 	sigs := make(chan os.Signal, 1)
 	failedHttp := make(chan bool, 1)
 	waiter := make(chan bool, 1)
@@ -58,15 +94,30 @@ func main() {
 			log.Println("failed to start the HTTP server, exiting...")
 		}
 		httpServer.Close()
+		if alertManager != nil {
+			alertManager.Stop()
+		}
+		if energyManager != nil {
+			energyManager.Stop()
+		}
+		for _, vc := range setupVentilation {
+			vc.Stop()
+		}
 		for _, zc := range setupZcan {
 			zc.Stop()
 		}
 		for _, md := range setupModbus {
-			md.Stop()
+			md.Close()
 		}
 		for _, m6 := range setupMax6675 {
 			m6.Stop()
 		}
+		for _, sd := range setupSysfs {
+			sd.Stop()
+		}
+		for _, w := range setupW1 {
+			w.Stop()
+		}
 		waiter <- true
 	}()
 	<-waiter
@@ -78,7 +129,42 @@ func main() {
 }
 
 func addZcan(node ZcanNode) error {
-	zc := zcan.NewZehnderDevice(node.NodeId)
+	zc := zcan.NewZehnderDevice(node.NodeId.ID)
+	if node.Name != "" {
+		zc.Name = node.Name
+	}
+	if node.NodeId.Auto {
+		from, to := byte(32), byte(63)
+		if len(node.NodeIdRange) == 2 {
+			from, to = node.NodeIdRange[0], node.NodeIdRange[1]
+		}
+		if err := zc.SetAutoNodeID(from, to); err != nil {
+			log.Printf("unable to use an automatic node id for zcan service %s: %s", node.Name, err)
+			return err
+		}
+	}
+	zc.SetQueryNodes(node.QueryNodes)
+	if node.PDOFile != "" {
+		if err := zcan.LoadPDOCatalogue(node.PDOFile); err != nil {
+			log.Printf("unable to load the PDO catalogue for zcan service %s: %s", node.Name, err)
+		}
+	}
+	var pipeline *reading.Pipeline
+	for _, pdo := range node.PDO.PDO {
+		if pdo.Slug == "" {
+			continue
+		}
+		pipeline = addReadingStages(pipeline, node.Name, strings.ToLower(pdo.Slug), pdo.ReadingOptions, zcan.PDOUnits(pdo.Slug))
+	}
+	zc.SetPipeline(pipeline)
+	if node.Capture != "" {
+		if err := zc.CaptureAll(node.Capture); err != nil {
+			log.Printf("unable to capture frames for zcan service %s: %s", node.Name, err)
+		}
+	}
+
+	addZcanErrors(zc, node)
+
 	if err := zc.Connect(node.Interface); err != nil {
 		log.Printf("unable to connect to %s for zcan service %s: %s", node.Interface, node.Name, err)
 		return err
@@ -100,30 +186,106 @@ func addZcan(node ZcanNode) error {
 	slug := endpointSlugify(node.Name)
 	AddEndpoint(JsonEndpoint{slug, zc.JsonResponse})
 	AddEndpoint(JsonEndpoint{fmt.Sprintf("%s/device-info", slug), zc.JsonDeviceInfo})
+	AddEndpoint(JsonEndpoint{fmt.Sprintf("%s/nodes", slug), zc.JsonNodes})
+	AddEndpoint(JsonEndpoint{fmt.Sprintf("%s/errors", slug), zc.JsonErrors})
+	AddActionEndpoint(ActionEndpoint{fmt.Sprintf("%s/rmi", slug), "GET", func(params url.Values) (map[string]interface{}, error) {
+		return rmiQuery(zc, params)
+	}})
+	addZcanFilter(zc, node, slug)
+	vc := newVentilationControl(zc, node.Schedule)
+	AddEndpoint(JsonEndpoint{fmt.Sprintf("%s/schedule", slug), vc.JsonResponse})
+	AddActionEndpoint(ActionEndpoint{fmt.Sprintf("%s/ventilation", slug), "POST", vc.action})
+	vc.Start()
+	setupVentilation = append(setupVentilation, vc)
 	log.Printf("zcan service %s setup OK", node.Name)
 	setupZcan = append(setupZcan, zc)
 	return nil
 }
 
+func addZcanErrors(zc *zcan.ZehnderDevice, node ZcanNode) {
+	es := zcan.ErrorSettings{
+		SubUnit:  node.Errors.SubUnit,
+		Type:     zcan.CN_UINT8,
+		Interval: node.Errors.Interval,
+	}
+	for _, ep := range node.Errors.Props {
+		es.Props = append(es.Props, zcan.ErrorProperty{
+			Prop:     ep.Prop,
+			Name:     ep.Name,
+			Severity: strings.ToLower(ep.Severity),
+			Message:  ep.Message,
+		})
+	}
+	if node.Errors.Type != "" {
+		typ, err := zcan.ParseZehnderType(node.Errors.Type)
+		if err != nil {
+			log.Printf("zcan service %s: error type: %s", node.Name, err)
+		} else {
+			es.Type = typ
+		}
+	}
+	if err := zc.SetErrorSettings(es); err != nil {
+		log.Printf("zcan service %s: %s", node.Name, err)
+	}
+}
+
+func addZcanFilter(zc *zcan.ZehnderDevice, node ZcanNode, slug string) {
+	fs := zcan.FilterSettings{
+		Unit:         node.Filter.Unit,
+		SubUnit:      node.Filter.SubUnit,
+		IntervalProp: node.Filter.IntervalProp,
+		IntervalType: zcan.CN_UINT16,
+		ResetProp:    node.Filter.ResetProp,
+		ResetValue:   node.Filter.ResetValue,
+	}
+	if node.Filter.IntervalType != "" {
+		typ, err := zcan.ParseZehnderType(node.Filter.IntervalType)
+		if err != nil {
+			log.Printf("zcan service %s: filter interval type: %s", node.Name, err)
+		} else {
+			fs.IntervalType = typ
+		}
+	}
+	zc.SetFilterSettings(fs)
+
+	fn := node.Filter.HistoryFile
+	if fn == "" {
+		fn = fmt.Sprintf("./%s_filters.json", strings.TrimPrefix(slug, "/"))
+	}
+	fm := newFilterMaintenance(zc, fn)
+	AddEndpoint(JsonEndpoint{fmt.Sprintf("%s/filter", slug), fm.JsonResponse})
+	AddActionEndpoint(ActionEndpoint{fmt.Sprintf("%s/filter/reset", slug), "POST", fm.reset})
+	AddActionEndpoint(ActionEndpoint{fmt.Sprintf("%s/filter/interval", slug), "POST", fm.setInterval})
+}
+
 func addModbus(node ModbusNode) error {
 	md := mdev.NewModbusDeviceLocal(node.Name, node.Device, node.SlaveId)
 	if node.Baudrate > 0 {
 		md.SetSerial(node.Baudrate)
 	}
+	if node.FrameDelay > 0 {
+		md.SetFrameDelay(time.Duration(node.FrameDelay) * time.Millisecond)
+	}
 	sort.Slice(node.Registers.Holding, func(i, j int) bool {
 		return node.Registers.Holding[i].Register < node.Registers.Holding[j].Register
 	})
 
 	for _, reg := range node.Registers.Holding {
-		md.AddRegister(reg.Description, reg.Tag, reg.Register, reg.Typ, reg.Factor, mdev.ModbusHolding, reg.Offset)
+		addModbusRegister(md, reg, mdev.ModbusHolding)
 	}
 
 	sort.Slice(node.Registers.Input, func(i, j int) bool {
 		return node.Registers.Input[i].Register < node.Registers.Input[j].Register
 	})
 	for _, reg := range node.Registers.Input {
-		md.AddRegister(reg.Description, reg.Tag, reg.Register, reg.Typ, reg.Factor, mdev.ModbusInput, reg.Offset)
+		addModbusRegister(md, reg, mdev.ModbusInput)
+	}
+
+	var pipeline *reading.Pipeline
+	for _, reg := range append(node.Registers.Holding, node.Registers.Input...) {
+		pipeline = addReadingStages(pipeline, node.Name, reg.Tag, reg.ReadingOptions, "")
 	}
+	md.SetPipeline(pipeline)
 
 	md.ReadOnce()
 	md.Start(node.Interval)
@@ -134,19 +296,208 @@ func addModbus(node ModbusNode) error {
 	return nil
 }
 
+func addModbusRegister(md *mdev.ModbusDevice, reg ModbusRegister, typ int) {
+	spec := mdev.RegisterSpec{
+		Description: reg.Description,
+		Tag:         reg.Tag,
+		Register:    reg.Register,
+		Format:      reg.Typ,
+		Type:        typ,
+		Factor:      reg.Factor,
+		Multiplier:  reg.Multiplier,
+		Offset:      reg.Offset,
+		Order:       reg.Order,
+		Length:      reg.Length,
+		Labels:      reg.Labels,
+		Interval:    time.Duration(reg.Interval) * time.Second,
+	}
+	if err := md.AddRegister(spec); err != nil {
+		log.Printf("%s: unable to add register: %s", md.Name, err)
+	}
+}
+
 func addMax6675(node Max6675Node) error {
 	m6 := max6675.NewMax6675(node.Name, node.Path, node.Interval)
+	opts := max6675.ChipOptions{
+		Type:              node.Type,
+		Averaging:         node.Averaging,
+		Wires:             node.Wires,
+		FilterHz:          node.Filter,
+		RefResistor:       node.RRef,
+		NominalResistance: node.RNominal,
+	}
+	if err := m6.SetChip(node.Chip, opts); err != nil {
+		log.Printf("unable to configure %s service %s: %s", strings.ToUpper(node.Chip), node.Name, err)
+		return err
+	}
+	m6.SetPipeline(addReadingStages(nil, node.Name, "temp", node.ReadingOptions, "°C"))
 	if err := m6.Start(); err != nil {
-		log.Printf("unable to start MAX6675 service %s: %s", node.Name, err)
+		log.Printf("unable to start %s service %s: %s", strings.ToUpper(m6.Chip), node.Name, err)
 		return err
 	}
 	name := endpointSlugify(node.Name)
 	AddEndpoint(JsonEndpoint{name, m6.JsonResponse})
-	log.Printf("MAX6675 service %s setup OK", node.Name)
+	log.Printf("%s service %s setup OK", strings.ToUpper(m6.Chip), node.Name)
 	setupMax6675 = append(setupMax6675, m6)
 	return nil
 }
 
+func addSysfs(node SysfsNode) error {
+	sd := sysfs.NewSysfsDevice(node.Name, node.Interval)
+	if node.Root != "" {
+		sd.Root = node.Root
+	}
+	var pipeline *reading.Pipeline
+	for _, attr := range node.Attributes {
+		if err := sd.AddAttribute(attr.Name, attr.Chip, attr.Path, attr.Scale, attr.Offset); err != nil {
+			log.Printf("unable to add sysfs attribute %s to %s: %s", attr.Name, node.Name, err)
+			continue
+		}
+		pipeline = addReadingStages(pipeline, node.Name, attr.Name, attr.ReadingOptions, "")
+	}
+	sd.SetPipeline(pipeline)
+	if err := sd.Start(); err != nil {
+		log.Printf("unable to start sysfs service %s: %s", node.Name, err)
+		return err
+	}
+	name := endpointSlugify(node.Name)
+	AddEndpoint(JsonEndpoint{name, sd.JsonResponse})
+	log.Printf("sysfs service %s setup OK", node.Name)
+	setupSysfs = append(setupSysfs, sd)
+	return nil
+}
+
+func addW1(node W1Node) error {
+	wd := w1.NewW1Device(node.Name, node.Interval)
+	if node.Path != "" {
+		wd.DevicesPath = node.Path
+	}
+	wd.AutoDiscover = node.AutoDiscover
+	var pipeline *reading.Pipeline
+	for _, probe := range node.Probes {
+		name := probe.Name
+		if name == "" {
+			name = strings.ToLower(probe.Id)
+		}
+		wd.AddProbe(probe.Id, name)
+		pipeline = addReadingStages(pipeline, node.Name, name, probe.ReadingOptions, "°C")
+	}
+	wd.SetPipeline(pipeline)
+	if err := wd.Start(); err != nil {
+		log.Printf("unable to start 1-Wire service %s: %s", node.Name, err)
+		return err
+	}
+	name := endpointSlugify(node.Name)
+	AddEndpoint(JsonEndpoint{name, wd.JsonResponse})
+	log.Printf("1-Wire service %s setup OK", node.Name)
+	setupW1 = append(setupW1, wd)
+	return nil
+}
+
+func addEnergy(node EnergyNode) {
+	stateFile := node.StateFile
+	if stateFile == "" {
+		stateFile = "./energy.json"
+	}
+	energyManager = energy.NewManager(node.Interval, stateFile)
+	for _, e := range endpoints {
+		energyManager.AddSource(strings.TrimPrefix(e.Endpoint, "/"), e.Handler)
+	}
+
+	for _, i := range node.Integrators {
+		var scale float64 = 1
+		switch strings.ToLower(i.Units) {
+		case "", "w":
+		case "kw":
+			scale = 1000
+		default:
+			log.Printf("energy integrator %s: unsupported units '%s'", i.Name, i.Units)
+			continue
+		}
+		ig := energy.NewIntegrator(i.Name, strings.TrimPrefix(endpointSlugify(i.Service), "/"), i.Reading, scale)
+		if err := energyManager.AddIntegrator(ig); err != nil {
+			log.Printf("unable to add energy integrator: %s", err)
+		}
+	}
+	if err := energyManager.Load(); err != nil {
+		log.Printf("unable to load energy counters from %s: %s", stateFile, err)
+	}
+
+	energyManager.Start()
+	AddEndpoint(JsonEndpoint{"/energy", energyManager.JsonResponse})
+	AddEndpoint(JsonEndpoint{"/energy/attributes", energyManager.JsonAttributes})
+	log.Printf("energy service setup OK")
+}
+
+func addAlerts(node AlertsNode) {
+	alertManager = alerts.NewManager(node.Interval)
+	for _, e := range endpoints {
+		alertManager.AddSource(strings.TrimPrefix(e.Endpoint, "/"), e.Handler)
+	}
+
+	for _, r := range node.Rules {
+		rule := alerts.Rule{
+			Name:       r.Name,
+			Service:    strings.TrimPrefix(endpointSlugify(r.Service), "/"),
+			Reading:    r.Reading,
+			Condition:  strings.ToLower(r.Condition),
+			Threshold:  r.Threshold,
+			Hysteresis: r.Hysteresis,
+			For:        time.Duration(r.For) * time.Second,
+		}
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("%s_%s_%s", rule.Service, rule.Reading, rule.Condition)
+		}
+		if err := alertManager.AddRule(&rule); err != nil {
+			log.Printf("unable to add alert rule: %s", err)
+		}
+	}
+
+	for _, n := range node.Notify {
+		switch strings.ToLower(n.Type) {
+		case "webhook":
+			alertManager.AddNotifier(alerts.NewWebhookNotifier(n.Url))
+		case "script":
+			alertManager.AddNotifier(alerts.NewScriptNotifier(n.Command, n.Args))
+		case "mqtt":
+			mq := alerts.NewMQTTNotifier(n.Broker, n.Topic)
+			if n.ClientId != "" {
+				mq.ClientID = n.ClientId
+			}
+			mq.Username = n.Username
+			mq.Password = n.Password
+			mq.Retain = n.Retain
+			alertManager.AddNotifier(mq)
+		default:
+			log.Printf("unknown alert notification type '%s'", n.Type)
+		}
+	}
+
+	// Conditions raised by the zcan devices, from the PDO catalogue or the
+	// unit error state, are passed to the notifiers alongside the rules.
+	for _, zc := range setupZcan {
+		service := strings.TrimPrefix(endpointSlugify(zc.Name), "/")
+		zc.SetStatusCallback(func(ev zcan.StatusEvent) {
+			alertManager.Report(alerts.Event{
+				Alarm: alerts.Alarm{
+					Rule:      fmt.Sprintf("%s_%s", service, ev.Name),
+					Service:   service,
+					Condition: ev.Severity,
+					Message:   ev.Message,
+					Value:     ev.Value,
+					Since:     ev.Since,
+				},
+				Active: ev.Active,
+				Time:   ev.Time,
+			})
+		})
+	}
+
+	alertManager.Start()
+	AddEndpoint(JsonEndpoint{"/alerts", alertManager.JsonResponse})
+	log.Printf("alerts service setup OK")
+}
+
 func endpointSlugify(orig string) string {
 	slug := strings.ToLower(orig)
 	slug = strings.ReplaceAll(slug, " ", "_")
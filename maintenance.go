@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zathras777/sensors/pkg/zcan"
+)
+
+// FilterEvent records a filter change or a change to the filter interval.
+type FilterEvent struct {
+	Time          time.Time   `json:"time"`
+	Action        string      `json:"action"`
+	Days          int         `json:"days,omitempty"`
+	RemainingDays interface{} `json:"remaining_days,omitempty"`
+}
+
+// filterMaintenance provides the filter endpoints for a zcan device and keeps
+// the history of filter changes in a file.
+type filterMaintenance struct {
+	zc      *zcan.ZehnderDevice
+	fn      string
+	mu      sync.Mutex
+	history []FilterEvent
+}
+
+func newFilterMaintenance(zc *zcan.ZehnderDevice, fn string) *filterMaintenance {
+	fm := filterMaintenance{zc: zc, fn: fn}
+	dat, err := os.ReadFile(fn)
+	if err == nil {
+		err = json.Unmarshal(dat, &fm.history)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("unable to load the filter history from %s: %s", fn, err)
+	}
+	return &fm
+}
+
+func (fm *filterMaintenance) record(ev FilterEvent) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	fm.history = append(fm.history, ev)
+	dat, err := json.MarshalIndent(fm.history, "", "  ")
+	if err == nil {
+		tmp := fm.fn + ".tmp"
+		if err = os.WriteFile(tmp, dat, 0644); err == nil {
+			err = os.Rename(tmp, fm.fn)
+		}
+	}
+	if err != nil {
+		log.Printf("unable to save the filter history to %s: %s", fm.fn, err)
+	}
+}
+
+func (fm *filterMaintenance) remainingDays() interface{} {
+	v, _ := fm.zc.PDOValue("filter_replacement_days")
+	return v
+}
+
+func (fm *filterMaintenance) JsonResponse() map[string]interface{} {
+	rv := map[string]interface{}{"remaining_days": fm.remainingDays()}
+	if days, err := fm.zc.FilterInterval(); err != nil {
+		rv["error"] = err.Error()
+	} else {
+		rv["interval_days"] = days
+	}
+	fm.mu.Lock()
+	rv["history"] = append([]FilterEvent{}, fm.history...)
+	fm.mu.Unlock()
+	return rv
+}
+
+func (fm *filterMaintenance) reset(params url.Values) (map[string]interface{}, error) {
+	remaining := fm.remainingDays()
+	if err := fm.zc.ResetFilter(); err != nil {
+		return nil, err
+	}
+	log.Printf("%s: filter counter reset", fm.zc.Name)
+	fm.record(FilterEvent{Time: time.Now(), Action: "reset", RemainingDays: remaining})
+	return map[string]interface{}{"reset": true}, nil
+}
+
+func (fm *filterMaintenance) setInterval(params url.Values) (map[string]interface{}, error) {
+	days, err := strconv.Atoi(params.Get("days"))
+	if err != nil || days <= 0 {
+		return nil, fmt.Errorf("days must be given as a positive number")
+	}
+	if err = fm.zc.SetFilterInterval(days); err != nil {
+		return nil, err
+	}
+	log.Printf("%s: filter interval set to %d days", fm.zc.Name, days)
+	fm.record(FilterEvent{Time: time.Now(), Action: "interval", Days: days})
+	return map[string]interface{}{"interval_days": days}, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/zathras777/sensors/pkg/reading"
+)
+
+func newFilter(fc FilterConfig) (reading.Stage, error) {
+	switch strings.ToLower(fc.Type) {
+	case "moving_average":
+		return reading.NewMovingAverage(fc.Window)
+	case "exponential":
+		return reading.NewExponential(fc.Alpha)
+	case "median":
+		return reading.NewMedian(fc.Window)
+	case "spike":
+		return reading.NewSpikeReject(fc.MaxRate, fc.MaxRejects)
+	case "clamp":
+		return reading.NewClamp(fc.Min, fc.Max)
+	case "deadband":
+		return reading.NewDeadband(fc.Band)
+	}
+	return nil, fmt.Errorf("unknown filter type '%s'", fc.Type)
+}
+
+func newCalibration(cc CalibrationConfig) ([]reading.Stage, error) {
+	var stages []reading.Stage
+	if len(cc.Table) > 0 {
+		var points [][2]float64
+		for _, pt := range cc.Table {
+			if len(pt) != 2 {
+				return nil, fmt.Errorf("lookup table entries must be [raw, actual] pairs")
+			}
+			points = append(points, [2]float64{pt[0], pt[1]})
+		}
+		tbl, err := reading.NewTable(points)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, tbl)
+	}
+	if len(cc.Polynomial) > 0 {
+		poly, err := reading.NewPolynomial(cc.Polynomial)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, poly)
+	}
+	if cc.Gain != nil || cc.Offset != 0 {
+		gain := 1.0
+		if cc.Gain != nil {
+			gain = *cc.Gain
+		}
+		stages = append(stages, reading.NewLinear(gain, cc.Offset))
+	}
+	return stages, nil
+}
+
+// addReadingStages adds the configured processing for a reading to the
+// pipeline, creating the pipeline if required. Calibration is applied first,
+// then unit conversion and finally any filters. The pipeline is returned so
+// that it can be passed to the device once all readings have been added.
+func addReadingStages(p *reading.Pipeline, service, name string, opts ReadingOptions, defaultUnits string) *reading.Pipeline {
+	var stages []reading.Stage
+
+	calib, err := newCalibration(opts.Calibration)
+	if err != nil {
+		log.Printf("%s: unable to add calibration to %s: %s", service, name, err)
+	}
+	stages = append(stages, calib...)
+
+	if opts.OutputUnits != "" {
+		units := opts.Units
+		if units == "" {
+			units = defaultUnits
+		}
+		conv, err := reading.NewConversion(units, opts.OutputUnits)
+		if err != nil {
+			log.Printf("%s: unable to convert units for %s: %s", service, name, err)
+		} else {
+			stages = append(stages, conv)
+		}
+	}
+
+	for _, fc := range opts.Filters {
+		stage, err := newFilter(fc)
+		if err != nil {
+			log.Printf("%s: unable to add filter to %s: %s", service, name, err)
+			continue
+		}
+		stages = append(stages, stage)
+	}
+
+	if len(stages) == 0 {
+		return p
+	}
+	if p == nil {
+		p = reading.NewPipeline()
+	}
+	for _, stage := range stages {
+		p.AddStage(name, stage)
+	}
+	return p
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/zathras777/sensors/pkg/reading"
+)
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func TestAddReadingStages(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ReadingOptions
+		raw  float64
+		want float64
+	}{
+		{"offset", ReadingOptions{Calibration: CalibrationConfig{Offset: -0.5}}, 20, 19.5},
+		{"gain and offset", ReadingOptions{Calibration: CalibrationConfig{Gain: floatPtr(0.1), Offset: 1}}, 200, 21},
+		{"table", ReadingOptions{Calibration: CalibrationConfig{Table: [][]float64{{0, 0}, {10, 20}}}}, 15, 30},
+		// the table is applied before the polynomial and the polynomial
+		// before the gain and offset
+		{"calibration order", ReadingOptions{Calibration: CalibrationConfig{
+			Table:      [][]float64{{0, 0}, {10, 30}},
+			Polynomial: []float64{1, 1},
+			Gain:       floatPtr(2),
+		}}, 5, 32},
+		// calibration is in the input units, before conversion
+		{"calibrated then converted", ReadingOptions{
+			Calibration: CalibrationConfig{Offset: 10},
+			OutputUnits: "°F",
+		}, 90, 212},
+		{"converted then filtered", ReadingOptions{
+			Units:       "W",
+			OutputUnits: "kW",
+			Filters:     []FilterConfig{{Type: "clamp", Max: floatPtr(2)}},
+		}, 2500, 2},
+	}
+	for _, tc := range tests {
+		p := addReadingStages(nil, "test", "value", tc.opts, "°C")
+		if p == nil {
+			t.Errorf("%s: no pipeline created", tc.name)
+			continue
+		}
+		p.Process("value", tc.raw)
+		rv := map[string]interface{}{"value": tc.raw}
+		p.Apply(rv)
+		if v, _ := reading.Float(rv["value"]); math.Abs(v-tc.want) > 1e-9 {
+			t.Errorf("%s: got %v, expected %v", tc.name, rv["value"], tc.want)
+		}
+		// the raw value is the reading before calibration
+		if rv["value_raw"] != tc.raw {
+			t.Errorf("%s: raw value %v, expected %v", tc.name, rv["value_raw"], tc.raw)
+		}
+	}
+}
+
+func TestAddReadingStagesErrors(t *testing.T) {
+	if p := addReadingStages(nil, "test", "value", ReadingOptions{}, "°C"); p != nil {
+		t.Error("a pipeline was created without any stages")
+	}
+	// stages that cannot be created are skipped
+	p := addReadingStages(nil, "test", "value", ReadingOptions{
+		OutputUnits: "kW",
+		Filters:     []FilterConfig{{Type: "unknown"}, {Type: "median", Window: 1}},
+	}, "°C")
+	if !p.HasStages("value") {
+		t.Fatal("expected the valid filter to be added")
+	}
+	p.Process("value", 21)
+	rv := map[string]interface{}{"value": 21.0}
+	p.Apply(rv)
+	if rv["value"] != 21.0 {
+		t.Errorf("got %v, expected the value unconverted", rv["value"])
+	}
+	if _, err := newCalibration(CalibrationConfig{Table: [][]float64{{0, 0, 1}, {1, 1}}}); err == nil {
+		t.Error("expected an error for a table entry that is not a pair")
+	}
+}
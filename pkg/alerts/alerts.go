@@ -0,0 +1,287 @@
+package alerts
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zathras777/sensors/pkg/reading"
+)
+
+const (
+	CondAbove = "above"
+	CondBelow = "below"
+	CondStale = "stale"
+	CondDown  = "down"
+	CondError = "error"
+)
+
+type Rule struct {
+	Name       string
+	Service    string
+	Reading    string
+	Condition  string
+	Threshold  float64
+	Hysteresis float64
+	For        time.Duration
+
+	active     bool
+	pending    time.Time
+	lastValue  string
+	lastChange time.Time
+}
+
+type Alarm struct {
+	Rule      string      `json:"rule"`
+	Service   string      `json:"service"`
+	Reading   string      `json:"reading,omitempty"`
+	Condition string      `json:"condition"`
+	Message   string      `json:"message"`
+	Value     interface{} `json:"value,omitempty"`
+	Since     time.Time   `json:"since"`
+}
+
+type Event struct {
+	Alarm
+	Active bool      `json:"active"`
+	Time   time.Time `json:"time"`
+}
+
+type Manager struct {
+	Interval time.Duration
+
+	mu        sync.Mutex
+	rules     []*Rule
+	sources   map[string]reading.Source
+	notifiers []Notifier
+	active    map[string]*Alarm
+	stopper   chan bool
+}
+
+func NewManager(interval int) *Manager {
+	if interval <= 0 {
+		interval = 10
+	}
+	return &Manager{
+		Interval: time.Duration(interval) * time.Second,
+		sources:  make(map[string]reading.Source),
+		active:   make(map[string]*Alarm),
+	}
+}
+
+func (m *Manager) AddSource(service string, src reading.Source) {
+	m.sources[service] = src
+}
+
+func (m *Manager) AddNotifier(n Notifier) {
+	m.notifiers = append(m.notifiers, n)
+}
+
+func (m *Manager) AddRule(rule *Rule) error {
+	switch rule.Condition {
+	case CondAbove, CondBelow:
+		if rule.Reading == "" {
+			return fmt.Errorf("rule %s: condition %s requires a reading", rule.Name, rule.Condition)
+		}
+	case CondStale:
+		if rule.Reading == "" || rule.For <= 0 {
+			return fmt.Errorf("rule %s: stale condition requires a reading and a duration", rule.Name)
+		}
+	case CondDown, CondError:
+	default:
+		return fmt.Errorf("rule %s: unknown condition '%s'", rule.Name, rule.Condition)
+	}
+	if rule.Hysteresis < 0 {
+		return fmt.Errorf("rule %s: hysteresis cannot be negative", rule.Name)
+	}
+	m.rules = append(m.rules, rule)
+	return nil
+}
+
+func (m *Manager) Start() {
+	if m.stopper != nil {
+		return
+	}
+	m.stopper = make(chan bool, 1)
+
+	go func() {
+		ticker := time.NewTicker(m.Interval)
+	alertLoop:
+		for {
+			select {
+			case <-ticker.C:
+				m.Check(time.Now())
+			case <-m.stopper:
+				break alertLoop
+			}
+		}
+		ticker.Stop()
+	}()
+}
+
+func (m *Manager) Stop() {
+	if m.stopper == nil {
+		return
+	}
+	m.stopper <- true
+}
+
+// Check evaluates every rule against the current readings of its service,
+// raising or clearing alarms as required.
+func (m *Manager) Check(now time.Time) {
+	cache := make(map[string]map[string]interface{})
+	var events []Event
+
+	m.mu.Lock()
+	for _, rule := range m.rules {
+		data, ck := cache[rule.Service]
+		if !ck {
+			src, found := m.sources[rule.Service]
+			if !found {
+				continue
+			}
+			data = src()
+			cache[rule.Service] = data
+		}
+
+		cond, known, value, msg := rule.evaluate(data, now)
+		if !known {
+			continue
+		}
+		if !cond {
+			rule.pending = time.Time{}
+			if rule.active {
+				rule.active = false
+				alarm := m.active[rule.Name]
+				delete(m.active, rule.Name)
+				if alarm != nil {
+					events = append(events, Event{*alarm, false, now})
+				}
+			}
+			continue
+		}
+		if rule.active {
+			m.active[rule.Name].Value = value
+			continue
+		}
+		if rule.pending.IsZero() {
+			rule.pending = now
+		}
+		if rule.Condition != CondStale && now.Sub(rule.pending) < rule.For {
+			continue
+		}
+		rule.active = true
+		alarm := &Alarm{rule.Name, rule.Service, rule.Reading, rule.Condition, msg, value, now}
+		m.active[rule.Name] = alarm
+		events = append(events, Event{*alarm, true, now})
+	}
+	m.mu.Unlock()
+	m.notify(events)
+}
+
+// Report records an alarm raised or cleared outside the rules, e.g. an error
+// reported by a device, and passes it to the notifiers. Alarms are keyed by
+// their rule name, so the name should be unique across services.
+func (m *Manager) Report(ev Event) {
+	m.mu.Lock()
+	if ev.Active {
+		alarm := ev.Alarm
+		m.active[ev.Rule] = &alarm
+	} else {
+		delete(m.active, ev.Rule)
+	}
+	m.mu.Unlock()
+	m.notify([]Event{ev})
+}
+
+func (m *Manager) notify(events []Event) {
+	for _, ev := range events {
+		if ev.Active {
+			log.Printf("alert %s raised: %s", ev.Rule, ev.Message)
+		} else {
+			log.Printf("alert %s cleared", ev.Rule)
+		}
+		for _, n := range m.notifiers {
+			if err := n.Notify(ev); err != nil {
+				log.Printf("unable to send notification for alert %s: %s", ev.Rule, err)
+			}
+		}
+	}
+}
+
+// evaluate returns whether the rule condition currently holds. known is false
+// when the data does not allow a decision, in which case the state is kept.
+func (r *Rule) evaluate(data map[string]interface{}, now time.Time) (cond bool, known bool, value interface{}, msg string) {
+	switch r.Condition {
+	case CondDown:
+		if e, ck := data["error"]; ck {
+			return true, true, nil, fmt.Sprintf("%s is not responding: %v", r.Service, e)
+		}
+		for _, v := range data {
+			if _, ok := reading.Float(v); ok {
+				return false, true, nil, ""
+			}
+		}
+		return true, true, nil, fmt.Sprintf("%s has no readings available", r.Service)
+	case CondError:
+		key := r.Reading
+		if key == "" {
+			key = "error"
+		}
+		e, ck := data[key]
+		if !ck {
+			return false, true, nil, ""
+		}
+		return true, true, e, fmt.Sprintf("%s reported an error: %v", r.Service, e)
+	case CondStale:
+		value = data[r.Reading]
+		if cur := fmt.Sprint(value); r.lastChange.IsZero() || cur != r.lastValue {
+			r.lastValue = cur
+			r.lastChange = now
+		}
+		age := now.Sub(r.lastChange)
+		return age >= r.For, true, value, fmt.Sprintf("%s %s has not changed for %s", r.Service, r.Reading, age.Round(time.Second))
+	}
+
+	value = data[r.Reading]
+	v, ok := reading.Float(value)
+	if !ok {
+		return false, false, value, ""
+	}
+	switch r.Condition {
+	case CondAbove:
+		limit := r.Threshold
+		if r.active {
+			limit -= r.Hysteresis
+		}
+		return v > limit, true, value, fmt.Sprintf("%s %s is %v, above %v", r.Service, r.Reading, v, r.Threshold)
+	case CondBelow:
+		limit := r.Threshold
+		if r.active {
+			limit += r.Hysteresis
+		}
+		return v < limit, true, value, fmt.Sprintf("%s %s is %v, below %v", r.Service, r.Reading, v, r.Threshold)
+	}
+	return false, false, value, ""
+}
+
+func (m *Manager) ActiveAlarms() []Alarm {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var rv []Alarm
+	for _, a := range m.active {
+		rv = append(rv, *a)
+	}
+	sort.Slice(rv, func(i, j int) bool { return rv[i].Since.Before(rv[j].Since) })
+	return rv
+}
+
+func (m *Manager) JsonResponse() map[string]interface{} {
+	rv := make(map[string]interface{})
+	for _, a := range m.ActiveAlarms() {
+		rv[a.Rule] = a
+	}
+	return rv
+}
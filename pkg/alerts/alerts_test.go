@@ -0,0 +1,161 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	events []Event
+}
+
+func (r *recorder) Notify(ev Event) error {
+	r.events = append(r.events, ev)
+	return nil
+}
+
+type step struct {
+	after  time.Duration
+	value  interface{}
+	active bool
+}
+
+func TestRuleStateMachine(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  Rule
+		steps []step
+	}{
+		{
+			name: "above with hysteresis",
+			rule: Rule{Condition: CondAbove, Threshold: 50, Hysteresis: 5},
+			steps: []step{
+				{0, 49.0, false},
+				{10 * time.Second, 51.0, true},
+				{20 * time.Second, 47.0, true},
+				{30 * time.Second, 45.0, false},
+				{40 * time.Second, 49.0, false},
+			},
+		},
+		{
+			name: "below with hysteresis",
+			rule: Rule{Condition: CondBelow, Threshold: 10, Hysteresis: 2},
+			steps: []step{
+				{0, 11, false},
+				{10 * time.Second, 9, true},
+				{20 * time.Second, 11, true},
+				{30 * time.Second, 12, false},
+			},
+		},
+		{
+			name: "above for a duration",
+			rule: Rule{Condition: CondAbove, Threshold: 50, For: 30 * time.Second},
+			steps: []step{
+				{0, 51.0, false},
+				{10 * time.Second, 52.0, false},
+				{30 * time.Second, 52.0, true},
+				{40 * time.Second, 40.0, false},
+			},
+		},
+		{
+			name: "pending reset when the condition clears",
+			rule: Rule{Condition: CondAbove, Threshold: 50, For: 30 * time.Second},
+			steps: []step{
+				{0, 51.0, false},
+				{20 * time.Second, 49.0, false},
+				{40 * time.Second, 51.0, false},
+				{60 * time.Second, 51.0, false},
+				{70 * time.Second, 51.0, true},
+			},
+		},
+		{
+			name: "missing reading keeps the state",
+			rule: Rule{Condition: CondAbove, Threshold: 50},
+			steps: []step{
+				{0, 51, true},
+				{10 * time.Second, nil, true},
+				{20 * time.Second, 40, false},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var value interface{}
+			m := NewManager(10)
+			m.AddSource("svc", func() map[string]interface{} {
+				if value == nil {
+					return map[string]interface{}{}
+				}
+				return map[string]interface{}{"temp": value}
+			})
+			rec := &recorder{}
+			m.AddNotifier(rec)
+			rule := tc.rule
+			rule.Name = "test"
+			rule.Service = "svc"
+			rule.Reading = "temp"
+			if err := m.AddRule(&rule); err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			active := false
+			for n, st := range tc.steps {
+				value = st.value
+				m.Check(start.Add(st.after))
+				if got := len(m.ActiveAlarms()) == 1; got != st.active {
+					t.Fatalf("step %d (%v): active %v, expected %v", n, st.value, got, st.active)
+				}
+				if st.active != active {
+					if len(rec.events) == 0 || rec.events[len(rec.events)-1].Active != st.active {
+						t.Fatalf("step %d: no event for the change to %v", n, st.active)
+					}
+					active = st.active
+				}
+			}
+		})
+	}
+}
+
+func TestAddRuleValidation(t *testing.T) {
+	tests := []struct {
+		rule Rule
+		ok   bool
+	}{
+		{Rule{Name: "a", Condition: CondAbove, Reading: "x"}, true},
+		{Rule{Name: "b", Condition: CondAbove}, false},
+		{Rule{Name: "c", Condition: CondStale, Reading: "x"}, false},
+		{Rule{Name: "d", Condition: CondStale, Reading: "x", For: time.Minute}, true},
+		{Rule{Name: "e", Condition: CondDown}, true},
+		{Rule{Name: "f", Condition: "sideways"}, false},
+		{Rule{Name: "g", Condition: CondBelow, Reading: "x", Hysteresis: -1}, false},
+	}
+	for _, tc := range tests {
+		m := NewManager(10)
+		rule := tc.rule
+		if err := m.AddRule(&rule); (err == nil) != tc.ok {
+			t.Errorf("rule %s: error %v, expected ok %v", tc.rule.Name, err, tc.ok)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	m := NewManager(10)
+	rec := &recorder{}
+	m.AddNotifier(rec)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	alarm := Alarm{Rule: "mvhr_filter_replace", Service: "mvhr", Condition: "error", Message: "The filter needs replacing", Since: now}
+
+	m.Report(Event{alarm, true, now})
+	if active := m.ActiveAlarms(); len(active) != 1 || active[0].Rule != alarm.Rule {
+		t.Fatalf("active alarms %v, expected %s", active, alarm.Rule)
+	}
+	m.Report(Event{alarm, false, now.Add(time.Minute)})
+	if active := m.ActiveAlarms(); len(active) != 0 {
+		t.Errorf("active alarms %v after clearing", active)
+	}
+	if len(rec.events) != 2 || !rec.events[0].Active || rec.events[1].Active {
+		t.Errorf("notified %v, expected a raise and a clear", rec.events)
+	}
+}
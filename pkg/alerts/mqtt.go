@@ -0,0 +1,97 @@
+package alerts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// MQTTNotifier publishes each event to a broker using MQTT 3.1.1 at QoS 0.
+// A new connection is made for every event, which is fine for the low rate
+// that alerts are generated at.
+type MQTTNotifier struct {
+	Broker   string
+	Topic    string
+	ClientID string
+	Username string
+	Password string
+	Retain   bool
+}
+
+func NewMQTTNotifier(broker, topic string) *MQTTNotifier {
+	return &MQTTNotifier{Broker: broker, Topic: topic, ClientID: "sensors-alerts"}
+}
+
+func (mq *MQTTNotifier) Notify(ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("tcp", mq.Broker, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	if _, err = conn.Write(mq.connectPacket()); err != nil {
+		return err
+	}
+	ack := make([]byte, 4)
+	if _, err = io.ReadFull(conn, ack); err != nil {
+		return err
+	}
+	if ack[0] != 0x20 || ack[3] != 0 {
+		return fmt.Errorf("mqtt broker %s refused connection, code %d", mq.Broker, ack[3])
+	}
+
+	topic := mq.Topic + "/" + ev.Rule
+	var hdr byte = 0x30
+	if mq.Retain {
+		hdr |= 0x01
+	}
+	pkt := append(mqttString(topic), body...)
+	if _, err = conn.Write(append(mqttHeader(hdr, len(pkt)), pkt...)); err != nil {
+		return err
+	}
+	_, err = conn.Write([]byte{0xE0, 0x00})
+	return err
+}
+
+func (mq *MQTTNotifier) connectPacket() []byte {
+	var flags byte = 0x02
+	payload := mqttString(mq.ClientID)
+	if mq.Username != "" {
+		flags |= 0x80
+		payload = append(payload, mqttString(mq.Username)...)
+		if mq.Password != "" {
+			flags |= 0x40
+			payload = append(payload, mqttString(mq.Password)...)
+		}
+	}
+	vhdr := append(mqttString("MQTT"), 0x04, flags, 0x00, 0x3C)
+	pkt := append(vhdr, payload...)
+	return append(mqttHeader(0x10, len(pkt)), pkt...)
+}
+
+func mqttString(s string) []byte {
+	return append([]byte{byte(len(s) >> 8), byte(len(s))}, s...)
+}
+
+func mqttHeader(typ byte, length int) []byte {
+	hdr := []byte{typ}
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		hdr = append(hdr, b)
+		if length == 0 {
+			break
+		}
+	}
+	return hdr
+}
@@ -0,0 +1,78 @@
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+)
+
+type Notifier interface {
+	Notify(ev Event) error
+}
+
+// WebhookNotifier POSTs each event as JSON to a URL.
+type WebhookNotifier struct {
+	URL    string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (wh *WebhookNotifier) Notify(ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	resp, err := wh.client.Post(wh.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned status %s", wh.URL, resp.Status)
+	}
+	return nil
+}
+
+// ScriptNotifier runs a command for each event. The event details are passed
+// in the environment and the JSON encoded event is written to stdin.
+type ScriptNotifier struct {
+	Command string
+	Args    []string
+}
+
+func NewScriptNotifier(command string, args []string) *ScriptNotifier {
+	return &ScriptNotifier{Command: command, Args: args}
+}
+
+func (sn *ScriptNotifier) Notify(ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	state := "cleared"
+	if ev.Active {
+		state = "raised"
+	}
+	cmd := exec.Command(sn.Command, sn.Args...)
+	cmd.Env = append(os.Environ(),
+		"ALERT_RULE="+ev.Rule,
+		"ALERT_STATE="+state,
+		"ALERT_SERVICE="+ev.Service,
+		"ALERT_READING="+ev.Reading,
+		"ALERT_CONDITION="+ev.Condition,
+		"ALERT_MESSAGE="+ev.Message,
+		fmt.Sprintf("ALERT_VALUE=%v", ev.Value),
+	)
+	cmd.Stdin = bytes.NewReader(body)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %s [%s]", sn.Command, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package energy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/zathras777/sensors/pkg/reading"
+)
+
+// Integrator turns an instantaneous power reading into energy totals using
+// trapezoidal integration between samples.
+type Integrator struct {
+	Name    string
+	Service string
+	Reading string
+	// Scale converts the reading into watts, e.g. 1000 for a reading in kW.
+	Scale float64
+	// Samples further apart than MaxGap are not integrated, as the power
+	// between them is unknown.
+	MaxGap time.Duration
+
+	state     counterState
+	lastPower float64
+	lastTime  time.Time
+}
+
+type counterState struct {
+	Total      float64   `json:"total"`
+	Daily      float64   `json:"daily"`
+	Monthly    float64   `json:"monthly"`
+	Yearly     float64   `json:"yearly"`
+	DayStart   time.Time `json:"day_start"`
+	MonthStart time.Time `json:"month_start"`
+	YearStart  time.Time `json:"year_start"`
+}
+
+func NewIntegrator(name, service, reading string, scale float64) *Integrator {
+	if scale == 0 {
+		scale = 1
+	}
+	return &Integrator{Name: name, Service: service, Reading: reading, Scale: scale}
+}
+
+// Sample adds a power reading taken at the given time.
+func (ig *Integrator) Sample(power float64, now time.Time) {
+	watts := power * ig.Scale
+	if watts < 0 {
+		watts = 0
+	}
+	ig.checkResets(now)
+
+	if !ig.lastTime.IsZero() && now.After(ig.lastTime) {
+		dt := now.Sub(ig.lastTime)
+		if ig.MaxGap == 0 || dt <= ig.MaxGap {
+			kwh := (ig.lastPower + watts) / 2 * dt.Hours() / 1000
+			ig.state.Total += kwh
+			ig.state.Daily += kwh
+			ig.state.Monthly += kwh
+			ig.state.Yearly += kwh
+		}
+	}
+	ig.lastPower = watts
+	ig.lastTime = now
+}
+
+func (ig *Integrator) checkResets(now time.Time) {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if !ig.state.DayStart.Equal(day) {
+		ig.state.DayStart = day
+		ig.state.Daily = 0
+	}
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	if !ig.state.MonthStart.Equal(month) {
+		ig.state.MonthStart = month
+		ig.state.Monthly = 0
+	}
+	year := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+	if !ig.state.YearStart.Equal(year) {
+		ig.state.YearStart = year
+		ig.state.Yearly = 0
+	}
+}
+
+type Manager struct {
+	Interval  time.Duration
+	StateFile string
+
+	mu          sync.Mutex
+	integrators []*Integrator
+	sources     map[string]reading.Source
+	lastSave    time.Time
+	stopper     chan bool
+}
+
+func NewManager(interval int, stateFile string) *Manager {
+	if interval <= 0 {
+		interval = 10
+	}
+	return &Manager{
+		Interval:  time.Duration(interval) * time.Second,
+		StateFile: stateFile,
+		sources:   make(map[string]reading.Source),
+	}
+}
+
+func (m *Manager) AddSource(service string, src reading.Source) {
+	m.sources[service] = src
+}
+
+func (m *Manager) AddIntegrator(ig *Integrator) error {
+	if _, ck := m.sources[ig.Service]; !ck {
+		return fmt.Errorf("integrator %s: no service named '%s'", ig.Name, ig.Service)
+	}
+	if ig.MaxGap == 0 {
+		ig.MaxGap = 5 * m.Interval
+	}
+	m.integrators = append(m.integrators, ig)
+	return nil
+}
+
+// Load restores the counters from the state file, if it exists.
+func (m *Manager) Load() error {
+	if m.StateFile == "" {
+		return nil
+	}
+	dat, err := os.ReadFile(m.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	saved := make(map[string]counterState)
+	if err = json.Unmarshal(dat, &saved); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ig := range m.integrators {
+		if st, ck := saved[ig.Name]; ck {
+			ig.state = st
+		}
+	}
+	return nil
+}
+
+func (m *Manager) Save() error {
+	if m.StateFile == "" {
+		return nil
+	}
+	m.mu.Lock()
+	saved := make(map[string]counterState)
+	for _, ig := range m.integrators {
+		saved[ig.Name] = ig.state
+	}
+	m.mu.Unlock()
+
+	dat, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := m.StateFile + ".tmp"
+	if err = os.WriteFile(tmp, dat, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.StateFile)
+}
+
+func (m *Manager) Start() {
+	if m.stopper != nil {
+		return
+	}
+	m.stopper = make(chan bool, 1)
+
+	go func() {
+		ticker := time.NewTicker(m.Interval)
+	energyLoop:
+		for {
+			select {
+			case <-ticker.C:
+				m.Update(time.Now())
+			case <-m.stopper:
+				break energyLoop
+			}
+		}
+		ticker.Stop()
+		if err := m.Save(); err != nil {
+			log.Printf("unable to save energy counters: %s", err)
+		}
+	}()
+}
+
+func (m *Manager) Stop() {
+	if m.stopper == nil {
+		return
+	}
+	m.stopper <- true
+}
+
+// Update samples every integrator's power reading.
+func (m *Manager) Update(now time.Time) {
+	cache := make(map[string]map[string]interface{})
+
+	m.mu.Lock()
+	for _, ig := range m.integrators {
+		data, ck := cache[ig.Service]
+		if !ck {
+			data = m.sources[ig.Service]()
+			cache[ig.Service] = data
+		}
+		if _, failed := data["error"]; failed {
+			continue
+		}
+		power, ok := reading.Float(data[ig.Reading])
+		if !ok {
+			continue
+		}
+		ig.Sample(power, now)
+	}
+	m.mu.Unlock()
+
+	if now.Sub(m.lastSave) >= time.Minute {
+		if err := m.Save(); err != nil {
+			log.Printf("unable to save energy counters: %s", err)
+		}
+		m.lastSave = now
+	}
+}
+
+func (m *Manager) JsonResponse() map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rv := make(map[string]interface{})
+	for _, ig := range m.integrators {
+		rv[ig.Name] = ig.state.Total
+		rv[ig.Name+"_daily"] = ig.state.Daily
+		rv[ig.Name+"_monthly"] = ig.state.Monthly
+		rv[ig.Name+"_yearly"] = ig.state.Yearly
+	}
+	return rv
+}
+
+// JsonAttributes describes each reading in the form HomeAssistant expects for
+// its energy dashboard.
+func (m *Manager) JsonAttributes() map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rv := make(map[string]interface{})
+	// Counters that are reset must use the total state class, as
+	// HomeAssistant does not allow last_reset with total_increasing.
+	attrs := func(lastReset time.Time) map[string]interface{} {
+		a := map[string]interface{}{
+			"unit_of_measurement": "kWh",
+			"device_class":        "energy",
+			"state_class":         "total_increasing",
+		}
+		if !lastReset.IsZero() {
+			a["state_class"] = "total"
+			a["last_reset"] = lastReset.Format(time.RFC3339)
+		}
+		return a
+	}
+	for _, ig := range m.integrators {
+		rv[ig.Name] = attrs(time.Time{})
+		rv[ig.Name+"_daily"] = attrs(ig.state.DayStart)
+		rv[ig.Name+"_monthly"] = attrs(ig.state.MonthStart)
+		rv[ig.Name+"_yearly"] = attrs(ig.state.YearStart)
+	}
+	return rv
+}
@@ -0,0 +1,176 @@
+package energy
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type sample struct {
+	after time.Duration
+	power float64
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestIntegration(t *testing.T) {
+	tests := []struct {
+		name    string
+		scale   float64
+		maxGap  time.Duration
+		samples []sample
+		total   float64
+	}{
+		{"constant power", 1, 0, []sample{{0, 1000}, {time.Hour, 1000}, {2 * time.Hour, 1000}}, 2},
+		{"ramp is trapezoidal", 1, 0, []sample{{0, 0}, {time.Hour, 1000}}, 0.5},
+		{"changing power", 1, 0, []sample{{0, 500}, {30 * time.Minute, 1500}, {time.Hour, 0}}, 0.875},
+		{"kW scaled to watts", 1000, 0, []sample{{0, 2}, {time.Hour, 2}}, 2},
+		{"negative treated as zero", 1, 0, []sample{{0, -500}, {time.Hour, -500}}, 0},
+		{"single sample", 1, 0, []sample{{0, 1000}}, 0},
+		{"samples out of order ignored", 1, 0, []sample{{time.Hour, 1000}, {0, 1000}}, 0},
+		{"gap larger than max gap skipped", 1, 10 * time.Minute, []sample{
+			{0, 1200}, {10 * time.Minute, 1200}, {2 * time.Hour, 1200}, {2*time.Hour + 10*time.Minute, 1200},
+		}, 0.4},
+		{"gap equal to max gap used", 1, time.Hour, []sample{{0, 1000}, {time.Hour, 1000}}, 1},
+	}
+
+	start := time.Date(2024, 3, 10, 8, 0, 0, 0, time.UTC)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ig := NewIntegrator("test", "svc", "power", tc.scale)
+			ig.MaxGap = tc.maxGap
+			for _, s := range tc.samples {
+				ig.Sample(s.power, start.Add(s.after))
+			}
+			if !near(ig.state.Total, tc.total) {
+				t.Errorf("total %v kWh, expected %v", ig.state.Total, tc.total)
+			}
+			if !near(ig.state.Daily, tc.total) || !near(ig.state.Monthly, tc.total) || !near(ig.state.Yearly, tc.total) {
+				t.Errorf("daily %v, monthly %v, yearly %v, expected all to be %v",
+					ig.state.Daily, ig.state.Monthly, ig.state.Yearly, tc.total)
+			}
+		})
+	}
+}
+
+func TestPeriodResets(t *testing.T) {
+	tests := []struct {
+		name                   string
+		from                   time.Time
+		daily, monthly, yearly float64
+	}{
+		{"same day", time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC), 2, 2, 2},
+		{"new day", time.Date(2024, 3, 10, 23, 30, 0, 0, time.UTC), 1, 2, 2},
+		{"new month", time.Date(2024, 2, 29, 23, 30, 0, 0, time.UTC), 1, 1, 2},
+		{"new year", time.Date(2023, 12, 31, 23, 30, 0, 0, time.UTC), 1, 1, 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ig := NewIntegrator("test", "svc", "power", 1)
+			// 1 kWh before the boundary, 1 kWh across it
+			ig.Sample(1000, tc.from.Add(-time.Hour))
+			ig.Sample(1000, tc.from)
+			ig.Sample(1000, tc.from.Add(time.Hour))
+			if !near(ig.state.Total, 2) {
+				t.Errorf("total %v, expected 2", ig.state.Total)
+			}
+			if !near(ig.state.Daily, tc.daily) || !near(ig.state.Monthly, tc.monthly) || !near(ig.state.Yearly, tc.yearly) {
+				t.Errorf("daily %v, monthly %v, yearly %v, expected %v, %v, %v",
+					ig.state.Daily, ig.state.Monthly, ig.state.Yearly, tc.daily, tc.monthly, tc.yearly)
+			}
+			now := tc.from.Add(time.Hour)
+			if want := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC); !ig.state.DayStart.Equal(want) {
+				t.Errorf("day start %v, expected %v", ig.state.DayStart, want)
+			}
+		})
+	}
+}
+
+func TestManagerUpdate(t *testing.T) {
+	data := map[string]interface{}{"power": 1000.0}
+	m := NewManager(10, "")
+	m.AddSource("svc", func() map[string]interface{} { return data })
+	if err := m.AddIntegrator(NewIntegrator("missing", "other", "power", 1)); err == nil {
+		t.Error("expected an error for an unknown service")
+	}
+	ig := NewIntegrator("house", "svc", "power", 1)
+	if err := m.AddIntegrator(ig); err != nil {
+		t.Fatal(err)
+	}
+	if ig.MaxGap != 50*time.Second {
+		t.Errorf("max gap %s, expected 5 intervals", ig.MaxGap)
+	}
+
+	start := time.Date(2024, 3, 10, 8, 0, 0, 0, time.UTC)
+	for n := 0; n <= 360; n++ {
+		m.Update(start.Add(time.Duration(n) * 10 * time.Second))
+	}
+	// readings with an error or no value are skipped
+	data = map[string]interface{}{"power": 1000.0, "error": "not responding"}
+	m.Update(start.Add(3610 * time.Second))
+	data = map[string]interface{}{"power": "unavailable"}
+	m.Update(start.Add(3620 * time.Second))
+
+	rv := m.JsonResponse()
+	if v := rv["house"].(float64); !near(v, 1) {
+		t.Errorf("total %v, expected 1 kWh", v)
+	}
+	if v := rv["house_daily"].(float64); !near(v, 1) {
+		t.Errorf("daily %v, expected 1 kWh", v)
+	}
+
+	attrs := m.JsonAttributes()
+	if a := attrs["house"].(map[string]interface{}); a["state_class"] != "total_increasing" || a["last_reset"] != nil {
+		t.Errorf("total attributes %v", a)
+	}
+	if a := attrs["house_daily"].(map[string]interface{}); a["state_class"] != "total" || a["last_reset"] != "2024-03-10T00:00:00Z" {
+		t.Errorf("daily attributes %v", a)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "energy.json")
+	m := NewManager(10, fn)
+	m.AddSource("svc", func() map[string]interface{} { return nil })
+	if err := m.Load(); err != nil {
+		t.Fatalf("a missing state file should not be an error: %s", err)
+	}
+	ig := NewIntegrator("house", "svc", "power", 1)
+	ig.MaxGap = 2 * time.Hour
+	m.AddIntegrator(ig)
+	start := time.Date(2024, 3, 10, 8, 0, 0, 0, time.UTC)
+	ig.Sample(1000, start)
+	ig.Sample(1000, start.Add(90*time.Minute))
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewManager(10, fn)
+	restored.AddSource("svc", func() map[string]interface{} { return nil })
+	rig := NewIntegrator("house", "svc", "power", 1)
+	rig.MaxGap = 2 * time.Hour
+	other := NewIntegrator("garage", "svc", "power", 1)
+	restored.AddIntegrator(rig)
+	restored.AddIntegrator(other)
+	if err := restored.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if !near(rig.state.Total, 1.5) || !near(rig.state.Daily, 1.5) || !rig.state.DayStart.Equal(ig.state.DayStart) {
+		t.Errorf("restored %+v, expected %+v", rig.state, ig.state)
+	}
+	if other.state.Total != 0 {
+		t.Errorf("an integrator with no saved state was given %v", other.state.Total)
+	}
+
+	// the counters continue from the saved values, resetting the daily
+	// counter on a new day
+	rig.Sample(1000, start.Add(24*time.Hour))
+	rig.Sample(1000, start.Add(25*time.Hour))
+	if !near(rig.state.Total, 2.5) || !near(rig.state.Daily, 1) || !near(rig.state.Monthly, 2.5) {
+		t.Errorf("after reloading got %+v", rig.state)
+	}
+}
@@ -0,0 +1,41 @@
+package max6675
+
+type max31855Chip struct{}
+
+func (max31855Chip) speed() int { return 5000000 }
+
+func (max31855Chip) configure(dev Transport) error {
+	return setupBus(dev, 0, 5000000)
+}
+
+// read decodes the 32 bit MAX31855 frame.
+//
+//	D31-D18 thermocouple temperature, signed, 0.25°C
+//	D16     fault
+//	D15-D4  internal (cold junction) temperature, signed, 0.0625°C
+//	D2      short to VCC, D1 short to GND, D0 open circuit
+func (max31855Chip) read(dev Transport) (s sample, err error) {
+	raw := []byte{0, 0, 0, 0}
+	if err = dev.Transfer(raw, raw); err != nil {
+		return
+	}
+	val := uint32(raw[0])<<24 | uint32(raw[1])<<16 | uint32(raw[2])<<8 | uint32(raw[3])
+
+	s.coldJunction = float64(int32(val<<16)>>20) * 0.0625
+	s.hasColdJunction = true
+
+	if val&0x10000 != 0 {
+		if val&0x01 != 0 {
+			s.faults = append(s.faults, FaultOpen)
+		}
+		if val&0x02 != 0 {
+			s.faults = append(s.faults, FaultShortGND)
+		}
+		if val&0x04 != 0 {
+			s.faults = append(s.faults, FaultShortVCC)
+		}
+		return
+	}
+	s.temp = float64(int32(val)>>18) * 0.25
+	return
+}
@@ -0,0 +1,83 @@
+package max6675
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMax31855Decode(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    []byte
+		temp   float64
+		cj     float64
+		faults []Fault
+	}{
+		{"zero", []byte{0x00, 0x00, 0x00, 0x00}, 0, 0, nil},
+		{"room temperature", []byte{0x01, 0x90, 0x19, 0x00}, 25, 25, nil},
+		{"maximum", []byte{0x64, 0x00, 0x7F, 0x00}, 1600, 127, nil},
+		{"quarter degree", []byte{0x00, 0x04, 0x00, 0x10}, 0.25, 0.0625, nil},
+		{"negative", []byte{0xFF, 0xFC, 0xFF, 0xF0}, -0.25, -0.0625, nil},
+		{"minimum", []byte{0xF0, 0x60, 0xEC, 0x00}, -250, -20, nil},
+		{"reserved bits ignored", []byte{0x01, 0x92, 0x19, 0x08}, 25, 25, nil},
+		{"fault bits without the fault flag ignored", []byte{0x01, 0x90, 0x19, 0x07}, 25, 25, nil},
+		{"open circuit", []byte{0x00, 0x01, 0x19, 0x01}, 0, 25, []Fault{FaultOpen}},
+		{"short to GND", []byte{0x00, 0x01, 0xEC, 0x02}, 0, -20, []Fault{FaultShortGND}},
+		{"short to VCC", []byte{0x00, 0x01, 0x19, 0x04}, 0, 25, []Fault{FaultShortVCC}},
+		{"all faults", []byte{0x7F, 0xFD, 0x19, 0x07}, 0, 25, []Fault{FaultOpen, FaultShortGND, FaultShortVCC}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ft := NewFakeTransport()
+			ft.Queue(tc.raw, nil)
+			s, err := max31855Chip{}.read(ft)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(s.faults, tc.faults) {
+				t.Errorf("faults %v, expected %v", s.faults, tc.faults)
+			}
+			if s.temp != tc.temp {
+				t.Errorf("temp %v, expected %v", s.temp, tc.temp)
+			}
+			// the cold junction is reported even when there is a fault
+			if !s.hasColdJunction || s.coldJunction != tc.cj {
+				t.Errorf("cold junction %v (%v), expected %v", s.coldJunction, s.hasColdJunction, tc.cj)
+			}
+		})
+	}
+}
+
+func TestMax31855ReadValue(t *testing.T) {
+	ft := NewFakeTransport()
+	ft.Queue([]byte{0x00, 0x01, 0x19, 0x02}, nil)
+	ft.Queue([]byte{0xFF, 0xFC, 0x19, 0x00}, nil)
+	m6 := NewMax6675("test", "", 1)
+	if err := m6.SetChip(ChipMAX31855, ChipOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	m6.SetTransport(ft)
+
+	if err := m6.readValue(); err == nil {
+		t.Error("expected an error for a fault")
+	}
+	rv := m6.JsonResponse()
+	if rv["temp"] != "unavailable" || rv["cold_junction"] != 25.0 || rv["error"] != "thermocouple shorted to GND" {
+		t.Errorf("fault reported as %v", rv)
+	}
+	if f, _ := rv["faults"].([]Fault); !reflect.DeepEqual(f, []Fault{FaultShortGND}) {
+		t.Errorf("faults %v, expected short_to_gnd", rv["faults"])
+	}
+
+	if err := m6.readValue(); err != nil {
+		t.Fatal(err)
+	}
+	rv = m6.JsonResponse()
+	if rv["temp"] != -0.25 || rv["cold_junction"] != 25.0 {
+		t.Errorf("got %v", rv)
+	}
+	if _, ck := rv["error"]; ck {
+		t.Error("the fault was not cleared")
+	}
+}
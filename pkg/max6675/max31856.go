@@ -0,0 +1,83 @@
+package max6675
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	max31856RegCR0  byte = 0x00
+	max31856RegCR1  byte = 0x01
+	max31856RegCJTH byte = 0x0A
+	max31856Write   byte = 0x80
+
+	max31856CR0AutoConvert byte = 0x80
+	max31856CR0OpenFault   byte = 0x10
+)
+
+var max31856Types = map[string]byte{
+	"B": 0, "E": 1, "J": 2, "K": 3, "N": 4, "R": 5, "S": 6, "T": 7,
+}
+
+var max31856Averaging = map[int]byte{
+	0: 0, 1: 0, 2: 1, 4: 2, 8: 3, 16: 4,
+}
+
+type max31856Chip struct {
+	tcType    byte
+	averaging byte
+}
+
+func newMax31856Chip(tcType string, averaging int) (chip, error) {
+	if tcType == "" {
+		tcType = "K"
+	}
+	typ, ck := max31856Types[strings.ToUpper(tcType)]
+	if !ck {
+		return nil, fmt.Errorf("unsupported thermocouple type '%s'", tcType)
+	}
+	avg, ck := max31856Averaging[averaging]
+	if !ck {
+		return nil, fmt.Errorf("averaging must be 1, 2, 4, 8 or 16 samples, not %d", averaging)
+	}
+	return max31856Chip{typ, avg}, nil
+}
+
+func (max31856Chip) speed() int { return 5000000 }
+
+func (c max31856Chip) configure(dev Transport) error {
+	if err := setupBus(dev, 1, 5000000); err != nil {
+		return err
+	}
+
+	cr0 := max31856CR0AutoConvert | max31856CR0OpenFault
+	cr1 := c.averaging<<4 | c.tcType
+	tx := []byte{max31856RegCR0 | max31856Write, cr0, cr1}
+	return dev.Transfer(tx, make([]byte, len(tx)))
+}
+
+// read fetches the cold junction, linearised thermocouple and fault status
+// registers (0x0A - 0x0F) in a single transfer.
+func (max31856Chip) read(dev Transport) (s sample, err error) {
+	raw := make([]byte, 7)
+	raw[0] = max31856RegCJTH
+	if err = dev.Transfer(raw, raw); err != nil {
+		return
+	}
+	cj := int16(uint16(raw[1])<<8 | uint16(raw[2]))
+	s.coldJunction = float64(cj>>2) * 0.015625
+	s.hasColdJunction = true
+
+	tc := int32(uint32(raw[3])<<24 | uint32(raw[4])<<16 | uint32(raw[5])<<8)
+	s.temp = float64(tc>>13) * 0.0078125
+
+	status := raw[6]
+	faultBits := []Fault{FaultOpen, FaultOverUnderVCC, FaultTCLow, FaultTCHigh,
+		FaultCJLow, FaultCJHigh, FaultTCRange, FaultCJRange}
+	for bit, f := range faultBits {
+		if status&(1<<bit) != 0 {
+			s.faults = append(s.faults, f)
+		}
+	}
+	return
+}
@@ -0,0 +1,96 @@
+package max6675
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestMax31856Decode(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    []byte
+		temp   float64
+		cj     float64
+		faults []Fault
+	}{
+		{"zero", []byte{0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 0, 0, nil},
+		{"room temperature", []byte{0, 0x19, 0x00, 0x01, 0x90, 0x00, 0x00}, 25, 25, nil},
+		{"fractions", []byte{0, 0x7D, 0x80, 0x06, 0x44, 0x00, 0x00}, 100.25, 125.5, nil},
+		{"high", []byte{0, 0x19, 0x00, 0x70, 0x88, 0x00, 0x00}, 1800.5, 25, nil},
+		{"smallest negative", []byte{0, 0xFF, 0xFC, 0xFF, 0xFF, 0xE0, 0x00}, -0.0078125, -0.015625, nil},
+		{"negative", []byte{0, 0xEC, 0x00, 0xF0, 0x60, 0x00, 0x00}, -250, -20, nil},
+		{"unused bits ignored", []byte{0, 0x19, 0x03, 0x01, 0x90, 0x1F, 0x00}, 25, 25, nil},
+		{"open circuit", []byte{0, 0x19, 0x00, 0x00, 0x00, 0x00, 0x01}, 0, 25, []Fault{FaultOpen}},
+		{"over or under voltage", []byte{0, 0x19, 0x00, 0x00, 0x00, 0x00, 0x02}, 0, 25, []Fault{FaultOverUnderVCC}},
+		{"thermocouple low", []byte{0, 0x19, 0x00, 0xF0, 0x60, 0x00, 0x04}, -250, 25, []Fault{FaultTCLow}},
+		{"thermocouple high", []byte{0, 0x19, 0x00, 0x70, 0x88, 0x00, 0x08}, 1800.5, 25, []Fault{FaultTCHigh}},
+		{"cold junction low", []byte{0, 0xEC, 0x00, 0x00, 0x00, 0x00, 0x10}, 0, -20, []Fault{FaultCJLow}},
+		{"cold junction high", []byte{0, 0x7D, 0x80, 0x00, 0x00, 0x00, 0x20}, 0, 125.5, []Fault{FaultCJHigh}},
+		{"thermocouple range", []byte{0, 0x19, 0x00, 0x00, 0x00, 0x00, 0x40}, 0, 25, []Fault{FaultTCRange}},
+		{"cold junction range", []byte{0, 0x19, 0x00, 0x00, 0x00, 0x00, 0x80}, 0, 25, []Fault{FaultCJRange}},
+		{"several faults", []byte{0, 0x19, 0x00, 0x00, 0x00, 0x00, 0x41}, 0, 25, []Fault{FaultOpen, FaultTCRange}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ft := NewFakeTransport()
+			ft.Queue(tc.raw, nil)
+			s, err := max31856Chip{}.read(ft)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(ft.Sent[0], []byte{max31856RegCJTH, 0, 0, 0, 0, 0, 0}) {
+				t.Errorf("sent % X, expected a read from the CJTH register", ft.Sent[0])
+			}
+			if !reflect.DeepEqual(s.faults, tc.faults) {
+				t.Errorf("faults %v, expected %v", s.faults, tc.faults)
+			}
+			if s.temp != tc.temp {
+				t.Errorf("temp %v, expected %v", s.temp, tc.temp)
+			}
+			if !s.hasColdJunction || s.coldJunction != tc.cj {
+				t.Errorf("cold junction %v (%v), expected %v", s.coldJunction, s.hasColdJunction, tc.cj)
+			}
+		})
+	}
+}
+
+func TestMax31856Configure(t *testing.T) {
+	tests := []struct {
+		tcType    string
+		averaging int
+		cr1       byte
+		ok        bool
+	}{
+		{"", 0, 0x03, true},
+		{"k", 1, 0x03, true},
+		{"J", 4, 0x22, true},
+		{"T", 16, 0x47, true},
+		{"B", 2, 0x10, true},
+		{"X", 1, 0, false},
+		{"K", 3, 0, false},
+	}
+	for _, tc := range tests {
+		c, err := newMax31856Chip(tc.tcType, tc.averaging)
+		if (err == nil) != tc.ok {
+			t.Errorf("type '%s' averaging %d: error %v, expected ok %v", tc.tcType, tc.averaging, err, tc.ok)
+			continue
+		}
+		if !tc.ok {
+			continue
+		}
+		ft := NewFakeTransport()
+		ft.Queue(nil, nil)
+		if err := c.configure(ft); err != nil {
+			t.Fatal(err)
+		}
+		if ft.Mode != 1 || ft.MaxSpeed != 5000000 {
+			t.Errorf("bus configured as mode %d, speed %d", ft.Mode, ft.MaxSpeed)
+		}
+		want := []byte{0x80, 0x90, tc.cr1}
+		if !bytes.Equal(ft.Sent[0], want) {
+			t.Errorf("type '%s' averaging %d: sent % X, expected % X", tc.tcType, tc.averaging, ft.Sent[0], want)
+		}
+	}
+}
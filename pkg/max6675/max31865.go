@@ -0,0 +1,129 @@
+package max6675
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	max31865RegConfig byte = 0x00
+	max31865RegRTD    byte = 0x01
+	max31865Write     byte = 0x80
+
+	max31865VBias       byte = 0x80
+	max31865AutoConvert byte = 0x40
+	max31865ThreeWire   byte = 0x10
+	max31865FaultClear  byte = 0x02
+	max31865Filter50Hz  byte = 0x01
+)
+
+// Callendar-Van Dusen coefficients for IEC 60751 platinum RTDs.
+const (
+	cvdA = 3.9083e-3
+	cvdB = -5.775e-7
+	cvdC = -4.183e-12
+)
+
+type max31865Chip struct {
+	config            byte
+	refResistor       float64
+	nominalResistance float64
+}
+
+func newMax31865Chip(opts ChipOptions) (chip, error) {
+	c := max31865Chip{
+		config:            max31865VBias | max31865AutoConvert,
+		refResistor:       opts.RefResistor,
+		nominalResistance: opts.NominalResistance,
+	}
+	if c.nominalResistance == 0 {
+		c.nominalResistance = 100
+	}
+	if c.refResistor == 0 {
+		// The usual reference resistors are 430R for a PT100 and 4k3 for a PT1000.
+		c.refResistor = c.nominalResistance * 4.3
+	}
+	switch opts.Wires {
+	case 3:
+		c.config |= max31865ThreeWire
+	case 0, 2, 4:
+	default:
+		return nil, fmt.Errorf("RTD must be 2, 3 or 4 wire, not %d", opts.Wires)
+	}
+	switch opts.FilterHz {
+	case 50:
+		c.config |= max31865Filter50Hz
+	case 0, 60:
+	default:
+		return nil, fmt.Errorf("filter must be 50 or 60 Hz, not %d", opts.FilterHz)
+	}
+	return c, nil
+}
+
+func (max31865Chip) speed() int { return 5000000 }
+
+func (c max31865Chip) configure(dev Transport) error {
+	if err := setupBus(dev, 1, 5000000); err != nil {
+		return err
+	}
+	return c.writeConfig(dev, c.config|max31865FaultClear)
+}
+
+func (c max31865Chip) writeConfig(dev Transport, config byte) error {
+	tx := []byte{max31865RegConfig | max31865Write, config}
+	return dev.Transfer(tx, make([]byte, len(tx)))
+}
+
+// read fetches the RTD, threshold and fault status registers (0x01 - 0x07)
+// in a single transfer. The RTD register holds the 15 bit ratio of the RTD
+// to reference resistance with the fault flag in bit 0.
+func (c max31865Chip) read(dev Transport) (s sample, err error) {
+	raw := make([]byte, 8)
+	raw[0] = max31865RegRTD
+	if err = dev.Transfer(raw, raw); err != nil {
+		return
+	}
+	rtd := uint16(raw[1])<<8 | uint16(raw[2])
+	if rtd&0x01 != 0 {
+		status := raw[7]
+		faultBits := map[byte]Fault{
+			0x80: FaultRTDHigh, 0x40: FaultRTDLow, 0x20: FaultRefInHigh,
+			0x10: FaultRefInLow, 0x08: FaultRTDInLow, 0x04: FaultOverUnderVCC,
+		}
+		for _, bit := range []byte{0x80, 0x40, 0x20, 0x10, 0x08, 0x04} {
+			if status&bit != 0 {
+				s.faults = append(s.faults, faultBits[bit])
+			}
+		}
+		if len(s.faults) == 0 {
+			s.faults = []Fault{FaultRTDHigh}
+		}
+		err = c.writeConfig(dev, c.config|max31865FaultClear)
+		return
+	}
+	s.resistance = float64(rtd>>1) / 32768 * c.refResistor
+	s.hasResistance = true
+	s.temp = rtdTemperature(s.resistance, c.nominalResistance)
+	return
+}
+
+// rtdTemperature converts a platinum RTD resistance to temperature using the
+// Callendar-Van Dusen equation. Above 0°C the quadratic form is solved
+// directly, below it Newton's method is used to include the C term.
+func rtdTemperature(r, r0 float64) float64 {
+	t := (-cvdA + math.Sqrt(cvdA*cvdA-4*cvdB*(1-r/r0))) / (2 * cvdB)
+	if t >= 0 {
+		// avoid returning -0 at exactly r0
+		return math.Max(t, 0)
+	}
+	for i := 0; i < 10; i++ {
+		f := r0*(1+cvdA*t+cvdB*t*t+cvdC*(t-100)*t*t*t) - r
+		df := r0 * (cvdA + 2*cvdB*t + cvdC*(4*t*t*t-300*t*t))
+		step := f / df
+		t -= step
+		if math.Abs(step) < 1e-6 {
+			break
+		}
+	}
+	return t
+}
@@ -0,0 +1,146 @@
+package max6675
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestRTDTemperature(t *testing.T) {
+	// resistances from the IEC 60751 tables
+	tests := []struct {
+		name string
+		r    float64
+		r0   float64
+		temp float64
+	}{
+		{"PT100 0°C", 100, 100, 0},
+		{"PT100 100°C", 138.5055, 100, 100},
+		{"PT100 200°C", 175.8560, 100, 200},
+		{"PT100 850°C", 390.4811, 100, 850},
+		{"PT100 -10°C", 96.0859, 100, -10},
+		{"PT100 -100°C", 60.2558, 100, -100},
+		{"PT100 -200°C", 18.5201, 100, -200},
+		{"PT1000 0°C", 1000, 1000, 0},
+		{"PT1000 100°C", 1385.055, 1000, 100},
+		{"PT1000 -40°C", 842.707, 1000, -40},
+		{"PT1000 -200°C", 185.201, 1000, -200},
+	}
+	for _, tc := range tests {
+		got := rtdTemperature(tc.r, tc.r0)
+		if math.Abs(got-tc.temp) > 0.001 {
+			t.Errorf("%s: %vΩ gave %v°C, expected %v°C", tc.name, tc.r, got, tc.temp)
+		}
+	}
+	if got := rtdTemperature(100, 100); math.Signbit(got) {
+		t.Errorf("got %v at the nominal resistance, expected 0", got)
+	}
+}
+
+func TestMax31865Read(t *testing.T) {
+	pt100, _ := newMax31865Chip(ChipOptions{})
+	pt1000, _ := newMax31865Chip(ChipOptions{NominalResistance: 1000, Wires: 3, FilterHz: 50})
+
+	tests := []struct {
+		name       string
+		chip       chip
+		raw        []byte
+		resistance float64
+		faults     []Fault
+		clear      []byte
+	}{
+		{"PT100 quarter of reference", pt100, []byte{0, 0x40, 0x00, 0, 0, 0, 0, 0}, 107.5, nil, nil},
+		{"PT1000 half of reference", pt1000, []byte{0, 0x80, 0x00, 0, 0, 0, 0, 0}, 2150, nil, nil},
+		{"PT100 RTD high", pt100, []byte{0, 0xFF, 0xFF, 0, 0, 0, 0, 0x80}, 0, []Fault{FaultRTDHigh}, []byte{0x80, 0xC2}},
+		{"PT100 RTD low", pt100, []byte{0, 0x00, 0x01, 0, 0, 0, 0, 0x40}, 0, []Fault{FaultRTDLow}, []byte{0x80, 0xC2}},
+		{"PT1000 REFIN high", pt1000, []byte{0, 0x00, 0x01, 0, 0, 0, 0, 0x20}, 0, []Fault{FaultRefInHigh}, []byte{0x80, 0xD3}},
+		{"REFIN low", pt100, []byte{0, 0x00, 0x01, 0, 0, 0, 0, 0x10}, 0, []Fault{FaultRefInLow}, []byte{0x80, 0xC2}},
+		{"RTDIN low", pt100, []byte{0, 0x00, 0x01, 0, 0, 0, 0, 0x08}, 0, []Fault{FaultRTDInLow}, []byte{0x80, 0xC2}},
+		{"over or under voltage", pt100, []byte{0, 0x00, 0x01, 0, 0, 0, 0, 0x04}, 0, []Fault{FaultOverUnderVCC}, []byte{0x80, 0xC2}},
+		{"several faults", pt100, []byte{0, 0x00, 0x01, 0, 0, 0, 0, 0x88}, 0, []Fault{FaultRTDHigh, FaultRTDInLow}, []byte{0x80, 0xC2}},
+		{"fault flag without status", pt100, []byte{0, 0x00, 0x01, 0, 0, 0, 0, 0x03}, 0, []Fault{FaultRTDHigh}, []byte{0x80, 0xC2}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ft := NewFakeTransport()
+			ft.Queue(tc.raw, nil)
+			ft.Queue(nil, nil)
+			s, err := tc.chip.read(ft)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(ft.Sent[0], []byte{max31865RegRTD, 0, 0, 0, 0, 0, 0, 0}) {
+				t.Errorf("sent % X, expected a read from the RTD register", ft.Sent[0])
+			}
+			if !reflect.DeepEqual(s.faults, tc.faults) {
+				t.Errorf("faults %v, expected %v", s.faults, tc.faults)
+			}
+			if tc.clear == nil {
+				if len(ft.Sent) != 1 {
+					t.Errorf("%d transfers, expected only the read", len(ft.Sent))
+				}
+				if !s.hasResistance || s.resistance != tc.resistance {
+					t.Errorf("resistance %v (%v), expected %v", s.resistance, s.hasResistance, tc.resistance)
+				}
+				if want := rtdTemperature(tc.resistance, tc.chip.(max31865Chip).nominalResistance); s.temp != want {
+					t.Errorf("temp %v, expected %v", s.temp, want)
+				}
+				return
+			}
+			// the fault status is cleared after it has been read
+			if len(ft.Sent) != 2 || !bytes.Equal(ft.Sent[1], tc.clear) {
+				t.Errorf("sent %X, expected the fault to be cleared with % X", ft.Sent, tc.clear)
+			}
+			if s.hasResistance {
+				t.Error("resistance reported with a fault")
+			}
+		})
+	}
+}
+
+func TestMax31865ClearError(t *testing.T) {
+	c, _ := newMax31865Chip(ChipOptions{})
+	ft := NewFakeTransport()
+	ft.Queue([]byte{0, 0x00, 0x01, 0, 0, 0, 0, 0x80}, nil)
+	ft.Queue(nil, errors.New("spi failure"))
+	s, err := c.read(ft)
+	if err == nil {
+		t.Error("expected the failure to clear the fault to be returned")
+	}
+	if !reflect.DeepEqual(s.faults, []Fault{FaultRTDHigh}) {
+		t.Errorf("faults %v, expected rtd_high", s.faults)
+	}
+}
+
+func TestNewMax31865Chip(t *testing.T) {
+	tests := []struct {
+		opts   ChipOptions
+		config byte
+		ref    float64
+		ok     bool
+	}{
+		{ChipOptions{}, 0xC0, 430, true},
+		{ChipOptions{NominalResistance: 1000}, 0xC0, 4300, true},
+		{ChipOptions{RefResistor: 400, Wires: 4, FilterHz: 60}, 0xC0, 400, true},
+		{ChipOptions{Wires: 3, FilterHz: 50}, 0xD1, 430, true},
+		{ChipOptions{Wires: 5}, 0, 0, false},
+		{ChipOptions{FilterHz: 55}, 0, 0, false},
+	}
+	for _, tc := range tests {
+		c, err := newMax31865Chip(tc.opts)
+		if (err == nil) != tc.ok {
+			t.Errorf("%+v: error %v, expected ok %v", tc.opts, err, tc.ok)
+			continue
+		}
+		if !tc.ok {
+			continue
+		}
+		mc := c.(max31865Chip)
+		if mc.config != tc.config || mc.refResistor != tc.ref {
+			t.Errorf("%+v: config %02X reference %v, expected %02X and %v", tc.opts, mc.config, mc.refResistor, tc.config, tc.ref)
+		}
+	}
+}
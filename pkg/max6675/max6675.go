@@ -3,21 +3,35 @@ package max6675
 import (
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/ecc1/spi"
+	"github.com/zathras777/sensors/pkg/reading"
 )
 
 type Max6675Device struct {
 	Name       string
 	DevicePath string
 	Interval   int
+	Chip       string
 
-	Value float64
+	Value        float64
+	ColdJunction float64
+	Resistance   float64
 
 	valueAvail  bool
-	spiDev      *spi.Device
+	cjAvail     bool
+	resAvail    bool
+	fault       string
+	faults      []Fault
+	chip        chip
+	spiDev      Transport
+	pipeline    *reading.Pipeline
 	stopChannel chan bool
+	done        chan bool
+	// tick overrides Interval when set, allowing shorter intervals in tests.
+	tick time.Duration
 }
 
 func NewMax6675(name string, path string, interval int) *Max6675Device {
@@ -25,18 +39,48 @@ func NewMax6675(name string, path string, interval int) *Max6675Device {
 		Name:        name,
 		DevicePath:  path,
 		Interval:    interval,
+		Chip:        ChipMAX6675,
+		chip:        max6675Chip{},
 		stopChannel: make(chan bool, 1),
 	}
 }
 
+// SetTransport supplies the SPI connection to use rather than opening
+// DevicePath when started.
+func (m6 *Max6675Device) SetTransport(t Transport) {
+	m6.spiDev = t
+}
+
+func (m6 *Max6675Device) SetPipeline(p *reading.Pipeline) {
+	m6.pipeline = p
+}
+
+// SetChip selects the converter in use, along with any options it requires.
+func (m6 *Max6675Device) SetChip(name string, opts ChipOptions) error {
+	c, err := newChip(name, opts)
+	if err != nil {
+		return err
+	}
+	if name != "" {
+		m6.Chip = strings.ToLower(name)
+	}
+	m6.chip = c
+	return nil
+}
+
 func (m6 *Max6675Device) Start() error {
 	if err := m6.openDevice(); err != nil {
 		log.Printf("unable to open %s: %s", m6.DevicePath, err)
 		return err
 	}
 
+	m6.done = make(chan bool)
 	go func() {
-		ticker := time.NewTicker(time.Duration(m6.Interval) * time.Second)
+		tick := time.Duration(m6.Interval) * time.Second
+		if m6.tick > 0 {
+			tick = m6.tick
+		}
+		ticker := time.NewTicker(tick)
 		errors := 0
 	m6Loop:
 		for {
@@ -50,6 +94,8 @@ func (m6 *Max6675Device) Start() error {
 						log.Printf("%d errors reading value, exiting read loop", errors)
 						break m6Loop
 					}
+				} else {
+					errors = 0
 				}
 			case <-m6.stopChannel:
 				break m6Loop
@@ -58,48 +104,70 @@ func (m6 *Max6675Device) Start() error {
 		}
 		ticker.Stop()
 		m6.spiDev.Close()
+		close(m6.done)
 	}()
 
 	return nil
 }
 
+// Stop asks the read loop to exit. It does not block if the loop has
+// already exited.
 func (m6 *Max6675Device) Stop() {
-	m6.stopChannel <- true
+	select {
+	case m6.stopChannel <- true:
+	default:
+	}
 }
 
-func (m6 *Max6675Device) openDevice() (err error) {
-	m6.spiDev, err = spi.Open(m6.DevicePath, 3900000, 0)
-
-	m6.spiDev.SetMode(0)
-	m6.spiDev.SetBitsPerWord(8)
-	m6.spiDev.SetLSBFirst(false)
-	m6.spiDev.SetMaxSpeed(3900000)
+// Wait blocks until the read loop has exited.
+func (m6 *Max6675Device) Wait() {
+	if m6.done != nil {
+		<-m6.done
+	}
+}
 
-	return
+func (m6 *Max6675Device) openDevice() error {
+	if m6.spiDev == nil {
+		dev, err := spi.Open(m6.DevicePath, m6.chip.speed(), 0)
+		if err != nil {
+			return err
+		}
+		m6.spiDev = dev
+	}
+	if err := m6.chip.configure(m6.spiDev); err != nil {
+		m6.spiDev.Close()
+		m6.spiDev = nil
+		return err
+	}
+	return nil
 }
 
 func (m6 *Max6675Device) readValue() (err error) {
-	raw := []byte{0, 0}
-
-	err = m6.spiDev.Transfer(raw, raw)
-
+	s, err := m6.chip.read(m6.spiDev)
 	if err != nil {
 		log.Printf("unable to read value from %s: %v", m6.DevicePath, err)
+		m6.valueAvail = false
+		m6.cjAvail = false
+		m6.resAvail = false
+		m6.fault = err.Error()
+		m6.faults = nil
 		return err
 	}
-	val := uint16(raw[0])<<8 | uint16(raw[1])
-	if val&0x04 == 0x04 {
+	m6.ColdJunction = s.coldJunction
+	m6.cjAvail = s.hasColdJunction
+	m6.Resistance = s.resistance
+	m6.resAvail = s.hasResistance
+	if len(s.faults) > 0 {
 		m6.valueAvail = false
-		return fmt.Errorf("invalid data returned. Marking as unavailable")
-	}
-	if val&0x8000 == 0x8000 {
-		val >>= 3
-		val -= 4096
-	} else {
-		val >>= 3
+		m6.faults = s.faults
+		m6.fault = describeFaults(s.faults)
+		return fmt.Errorf("%s reported. Marking as unavailable", m6.fault)
 	}
-	m6.Value = float64(val) * .25
+	m6.Value = s.temp
+	m6.pipeline.Process("temp", m6.Value)
 	m6.valueAvail = true
+	m6.fault = ""
+	m6.faults = nil
 	return nil
 }
 
@@ -110,5 +178,18 @@ func (m6 *Max6675Device) JsonResponse() map[string]interface{} {
 	} else {
 		rv["temp"] = "unavailable"
 	}
+	m6.pipeline.Apply(rv)
+	if m6.cjAvail {
+		rv["cold_junction"] = m6.ColdJunction
+	}
+	if m6.resAvail {
+		rv["resistance"] = m6.Resistance
+	}
+	if m6.fault != "" {
+		rv["error"] = m6.fault
+	}
+	if len(m6.faults) > 0 {
+		rv["faults"] = m6.faults
+	}
 	return rv
 }
@@ -0,0 +1,165 @@
+package max6675
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMax6675Decode(t *testing.T) {
+	tests := []struct {
+		name  string
+		raw   []byte
+		temp  float64
+		fault Fault
+	}{
+		{"zero", []byte{0x00, 0x00}, 0, ""},
+		{"quarter degree", []byte{0x00, 0x08}, 0.25, ""},
+		{"half degree", []byte{0x00, 0x10}, 0.5, ""},
+		{"three quarters", []byte{0x00, 0x18}, 0.75, ""},
+		{"room temperature", []byte{0x02, 0xE0}, 23, ""},
+		{"maximum", []byte{0x7F, 0xF8}, 1023.75, ""},
+		{"dummy bit ignored", []byte{0x80, 0x00}, 0, ""},
+		{"dummy bit with maximum", []byte{0xFF, 0xF8}, 1023.75, ""},
+		{"device id and state bits ignored", []byte{0x02, 0xE3}, 23, ""},
+		{"open thermocouple", []byte{0x00, 0x04}, 0, FaultOpen},
+		{"open thermocouple with value", []byte{0x7F, 0xFC}, 0, FaultOpen},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ft := NewFakeTransport()
+			ft.Queue(tc.raw, nil)
+			s, err := max6675Chip{}.read(ft)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.fault != "" {
+				if len(s.faults) != 1 || s.faults[0] != tc.fault {
+					t.Errorf("faults %v, expected %s", s.faults, tc.fault)
+				}
+				return
+			}
+			if len(s.faults) > 0 {
+				t.Errorf("unexpected faults %v", s.faults)
+			}
+			if s.temp != tc.temp {
+				t.Errorf("temp %v, expected %v", s.temp, tc.temp)
+			}
+		})
+	}
+}
+
+func TestMax6675ReadValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    []byte
+		err    error
+		result interface{}
+		fault  string
+	}{
+		{"value", []byte{0x02, 0xE0}, nil, 23.0, ""},
+		{"open thermocouple", []byte{0x00, 0x04}, nil, "unavailable", "open thermocouple"},
+		{"transfer error", nil, errors.New("spi failure"), "unavailable", "spi failure"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ft := NewFakeTransport()
+			ft.Queue(tc.raw, tc.err)
+			m6 := NewMax6675("test", "", 1)
+			m6.SetTransport(ft)
+			err := m6.readValue()
+			if (err != nil) != (tc.fault != "") {
+				t.Errorf("error %v, expected a fault of '%s'", err, tc.fault)
+			}
+			rv := m6.JsonResponse()
+			if rv["temp"] != tc.result {
+				t.Errorf("temp %v, expected %v", rv["temp"], tc.result)
+			}
+			if tc.fault != "" && rv["error"] != tc.fault {
+				t.Errorf("error %v, expected %s", rv["error"], tc.fault)
+			}
+		})
+	}
+}
+
+func TestStartConfiguresBus(t *testing.T) {
+	ft := NewFakeTransport()
+	m6 := NewMax6675("test", "", 1)
+	m6.SetTransport(ft)
+	if err := m6.Start(); err != nil {
+		t.Fatal(err)
+	}
+	m6.Stop()
+	m6.Wait()
+	if ft.Mode != 0 || ft.BitsPerWord != 8 || ft.LSBFirst || ft.MaxSpeed != 3900000 {
+		t.Errorf("bus configured as mode %d, %d bits, lsb %v, speed %d", ft.Mode, ft.BitsPerWord, ft.LSBFirst, ft.MaxSpeed)
+	}
+	if !ft.Closed {
+		t.Error("transport not closed after stopping")
+	}
+}
+
+func TestStartSetupError(t *testing.T) {
+	ft := NewFakeTransport()
+	ft.SetupErr = errors.New("no such device")
+	m6 := NewMax6675("test", "", 1)
+	m6.SetTransport(ft)
+	if err := m6.Start(); err == nil {
+		t.Fatal("expected an error")
+	}
+	if !ft.Closed {
+		t.Error("transport not closed after a setup error")
+	}
+}
+
+func TestStartReadsUntilStopped(t *testing.T) {
+	ft := NewFakeTransport()
+	for n := 0; n < 3; n++ {
+		ft.Queue([]byte{0x02, 0xE0}, nil)
+	}
+	m6 := NewMax6675("test", "", 1)
+	m6.tick = time.Millisecond
+	m6.SetTransport(ft)
+	if err := m6.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for ft.Pending() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	m6.Stop()
+	m6.Wait()
+	if !ft.Closed {
+		t.Error("transport not closed after stopping")
+	}
+	// stopping again after the loop has exited must not block
+	m6.Stop()
+}
+
+func TestStartExitsAfterRepeatedErrors(t *testing.T) {
+	ft := NewFakeTransport()
+	m6 := NewMax6675("test", "", 1)
+	m6.tick = time.Millisecond
+	m6.SetTransport(ft)
+	if err := m6.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan bool)
+	go func() {
+		m6.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("read loop did not exit after repeated errors")
+	}
+	if got := len(ft.Sent); got != 11 {
+		t.Errorf("%d reads attempted, expected 11", got)
+	}
+	if !ft.Closed {
+		t.Error("transport not closed after the loop exited")
+	}
+}
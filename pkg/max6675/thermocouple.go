@@ -0,0 +1,127 @@
+package max6675
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	ChipMAX6675  string = "max6675"
+	ChipMAX31855 string = "max31855"
+	ChipMAX31856 string = "max31856"
+	ChipMAX31865 string = "max31865"
+)
+
+// ChipOptions holds the settings for converters that need configuring.
+type ChipOptions struct {
+	// MAX31856 thermocouple type and number of samples averaged.
+	Type      string
+	Averaging int
+	// MAX31865 RTD wiring (2, 3 or 4 wire), mains filter frequency and
+	// the reference and nominal (0°C) resistances in ohms.
+	Wires             int
+	FilterHz          int
+	RefResistor       float64
+	NominalResistance float64
+}
+
+// Fault is a fault condition reported by the converter.
+type Fault string
+
+const (
+	FaultOpen         Fault = "open_circuit"
+	FaultShortGND     Fault = "short_to_gnd"
+	FaultShortVCC     Fault = "short_to_vcc"
+	FaultCJRange      Fault = "cold_junction_range"
+	FaultTCRange      Fault = "thermocouple_range"
+	FaultCJHigh       Fault = "cold_junction_high"
+	FaultCJLow        Fault = "cold_junction_low"
+	FaultTCHigh       Fault = "thermocouple_high"
+	FaultTCLow        Fault = "thermocouple_low"
+	FaultOverUnderVCC Fault = "over_under_voltage"
+	FaultRTDHigh      Fault = "rtd_high"
+	FaultRTDLow       Fault = "rtd_low"
+	FaultRefInHigh    Fault = "refin_high"
+	FaultRefInLow     Fault = "refin_low"
+	FaultRTDInLow     Fault = "rtdin_low"
+)
+
+var faultDescriptions = map[Fault]string{
+	FaultOpen:         "open thermocouple",
+	FaultShortGND:     "thermocouple shorted to GND",
+	FaultShortVCC:     "thermocouple shorted to VCC",
+	FaultCJRange:      "cold junction temperature out of range",
+	FaultTCRange:      "thermocouple temperature out of range",
+	FaultCJHigh:       "cold junction temperature above high threshold",
+	FaultCJLow:        "cold junction temperature below low threshold",
+	FaultTCHigh:       "thermocouple temperature above high threshold",
+	FaultTCLow:        "thermocouple temperature below low threshold",
+	FaultOverUnderVCC: "input over or under voltage",
+	FaultRTDHigh:      "RTD resistance above high threshold",
+	FaultRTDLow:       "RTD resistance below low threshold",
+	FaultRefInHigh:    "REFIN- above 0.85 x VBIAS",
+	FaultRefInLow:     "REFIN- below 0.85 x VBIAS, FORCE- open",
+	FaultRTDInLow:     "RTDIN- below 0.85 x VBIAS, FORCE- open",
+}
+
+func describeFaults(faults []Fault) string {
+	var desc []string
+	for _, f := range faults {
+		desc = append(desc, faultDescriptions[f])
+	}
+	return strings.Join(desc, ", ")
+}
+
+type sample struct {
+	temp            float64
+	coldJunction    float64
+	hasColdJunction bool
+	resistance      float64
+	hasResistance   bool
+	faults          []Fault
+}
+
+// chip handles the configuration and frame decoding for a converter.
+type chip interface {
+	speed() int
+	configure(dev Transport) error
+	read(dev Transport) (sample, error)
+}
+
+func newChip(name string, opts ChipOptions) (chip, error) {
+	switch strings.ToLower(name) {
+	case "", ChipMAX6675:
+		return max6675Chip{}, nil
+	case ChipMAX31855:
+		return max31855Chip{}, nil
+	case ChipMAX31856:
+		return newMax31856Chip(opts.Type, opts.Averaging)
+	case ChipMAX31865:
+		return newMax31865Chip(opts)
+	}
+	return nil, fmt.Errorf("unknown temperature converter '%s'", name)
+}
+
+type max6675Chip struct{}
+
+func (max6675Chip) speed() int { return 3900000 }
+
+func (max6675Chip) configure(dev Transport) error {
+	return setupBus(dev, 0, 3900000)
+}
+
+func (max6675Chip) read(dev Transport) (s sample, err error) {
+	raw := []byte{0, 0}
+	if err = dev.Transfer(raw, raw); err != nil {
+		return
+	}
+	val := uint16(raw[0])<<8 | uint16(raw[1])
+	if val&0x04 == 0x04 {
+		s.faults = []Fault{FaultOpen}
+		return
+	}
+	// D15 is a dummy bit and the temperature, D14-D3, is never negative.
+	val = (val &^ 0x8000) >> 3
+	s.temp = float64(val) * .25
+	return
+}
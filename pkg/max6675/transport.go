@@ -0,0 +1,119 @@
+package max6675
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ecc1/spi"
+)
+
+// Transport is the SPI connection used to talk to a converter. It is
+// satisfied by *spi.Device.
+type Transport interface {
+	Transfer(tx, rx []byte) error
+	SetMode(mode uint8) error
+	SetBitsPerWord(n int) error
+	SetLSBFirst(lsb bool) error
+	SetMaxSpeed(n int) error
+	Close() error
+}
+
+var _ Transport = (*spi.Device)(nil)
+
+func setupBus(dev Transport, mode uint8, speed int) error {
+	if err := dev.SetMode(mode); err != nil {
+		return fmt.Errorf("unable to set SPI mode %d: %w", mode, err)
+	}
+	if err := dev.SetBitsPerWord(8); err != nil {
+		return fmt.Errorf("unable to set SPI bits per word: %w", err)
+	}
+	if err := dev.SetLSBFirst(false); err != nil {
+		return fmt.Errorf("unable to set SPI bit order: %w", err)
+	}
+	if err := dev.SetMaxSpeed(speed); err != nil {
+		return fmt.Errorf("unable to set SPI speed %d: %w", speed, err)
+	}
+	return nil
+}
+
+type FakeResponse struct {
+	Data []byte
+	Err  error
+}
+
+// FakeTransport is a scriptable Transport for use without hardware. Each
+// Transfer returns the next queued response, and everything sent is
+// recorded.
+type FakeTransport struct {
+	Mode        uint8
+	BitsPerWord int
+	LSBFirst    bool
+	MaxSpeed    int
+	Closed      bool
+	SetupErr    error
+	Sent        [][]byte
+
+	mu        sync.Mutex
+	responses []FakeResponse
+}
+
+func NewFakeTransport() *FakeTransport {
+	return &FakeTransport{}
+}
+
+// Queue adds a response for a future Transfer. If err is not nil the
+// transfer fails with it, otherwise data is copied into the receive buffer.
+func (ft *FakeTransport) Queue(data []byte, err error) {
+	ft.mu.Lock()
+	ft.responses = append(ft.responses, FakeResponse{data, err})
+	ft.mu.Unlock()
+}
+
+func (ft *FakeTransport) Pending() int {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	return len(ft.responses)
+}
+
+func (ft *FakeTransport) Transfer(tx, rx []byte) error {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	ft.Sent = append(ft.Sent, append([]byte(nil), tx...))
+	if len(ft.responses) == 0 {
+		return fmt.Errorf("no response queued")
+	}
+	resp := ft.responses[0]
+	ft.responses = ft.responses[1:]
+	if resp.Err != nil {
+		return resp.Err
+	}
+	copy(rx, resp.Data)
+	return nil
+}
+
+func (ft *FakeTransport) SetMode(mode uint8) error {
+	ft.Mode = mode
+	return ft.SetupErr
+}
+
+func (ft *FakeTransport) SetBitsPerWord(n int) error {
+	ft.BitsPerWord = n
+	return ft.SetupErr
+}
+
+func (ft *FakeTransport) SetLSBFirst(lsb bool) error {
+	ft.LSBFirst = lsb
+	return ft.SetupErr
+}
+
+func (ft *FakeTransport) SetMaxSpeed(n int) error {
+	ft.MaxSpeed = n
+	return ft.SetupErr
+}
+
+func (ft *FakeTransport) Close() error {
+	ft.mu.Lock()
+	ft.Closed = true
+	ft.mu.Unlock()
+	return nil
+}
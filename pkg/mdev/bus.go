@@ -0,0 +1,130 @@
+package mdev
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/goburrow/modbus"
+)
+
+// Bus owns a single RS-485 serial port that may be shared by several slave
+// devices. Transactions from all devices on the bus are serialised, with a
+// delay between the end of one and the start of the next.
+type Bus struct {
+	Device     string
+	FrameDelay time.Duration
+
+	mu        sync.Mutex
+	handler   *modbus.RTUClientHandler
+	client    modbus.Client
+	connected bool
+	lastTx    time.Time
+	users     int
+}
+
+var buses = make(map[string]*Bus)
+var busesMu sync.Mutex
+
+// GetBus returns the bus for the serial device, creating it if required.
+// Each call should be matched by a call to Release.
+func GetBus(device string) *Bus {
+	busesMu.Lock()
+	defer busesMu.Unlock()
+
+	bus, ck := buses[device]
+	if !ck {
+		bus = &Bus{Device: device}
+		bus.handler = modbus.NewRTUClientHandler(device)
+		bus.client = modbus.NewClient(bus.handler)
+		buses[device] = bus
+	}
+	bus.users++
+	return bus
+}
+
+// Release closes the port once the last device using the bus has released it.
+func (b *Bus) Release() {
+	busesMu.Lock()
+	defer busesMu.Unlock()
+
+	b.users--
+	if b.users > 0 {
+		return
+	}
+	b.mu.Lock()
+	if b.connected {
+		b.handler.Close()
+		b.connected = false
+	}
+	b.mu.Unlock()
+	delete(buses, b.Device)
+}
+
+// SetSerial sets the baud rate for the bus. All devices on a bus must use the
+// same rate, so a conflicting request is logged and ignored.
+func (b *Bus) SetSerial(spd int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.users > 1 && b.handler.BaudRate != 0 && b.handler.BaudRate != spd {
+		log.Printf("%s: ignoring baud rate %d, bus is already using %d", b.Device, spd, b.handler.BaudRate)
+		return
+	}
+	b.handler.BaudRate = spd
+}
+
+// SetFrameDelay sets the minimum gap between transactions. If 0 the default
+// for the baud rate is used.
+func (b *Bus) SetFrameDelay(delay time.Duration) {
+	b.mu.Lock()
+	b.FrameDelay = delay
+	b.mu.Unlock()
+}
+
+// frameDelay is the gap left between transactions, by default 3.5 character
+// times or 1.75ms above 19200 baud, as per the modbus serial line spec. The
+// bus must be locked.
+func (b *Bus) frameDelay() time.Duration {
+	if b.FrameDelay > 0 {
+		return b.FrameDelay
+	}
+	baud := b.handler.BaudRate
+	if baud <= 0 {
+		baud = 19200
+	}
+	if baud > 19200 {
+		return 1750 * time.Microsecond
+	}
+	return time.Duration(38500000/baud) * time.Microsecond
+}
+
+// Transaction runs fn with exclusive use of the bus, addressed to the slave.
+func (b *Bus) Transaction(slave byte, fn func(modbus.Client) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.connected {
+		if err := b.handler.Connect(); err != nil {
+			return err
+		}
+		b.connected = true
+	}
+	if wait := b.frameDelay() - time.Since(b.lastTx); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	b.handler.SlaveId = slave
+	err := fn(b.client)
+	b.lastTx = time.Now()
+
+	// A modbus exception means the slave answered, anything else may be a
+	// problem with the port so reopen it for the next transaction.
+	var mbErr *modbus.ModbusError
+	if err != nil && !errors.As(err, &mbErr) {
+		b.handler.Close()
+		b.connected = false
+	}
+	return err
+}
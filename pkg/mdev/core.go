@@ -3,8 +3,11 @@ package mdev
 import (
 	"fmt"
 	"log"
+	"sort"
+	"time"
 
 	"github.com/goburrow/modbus"
+	"github.com/zathras777/sensors/pkg/reading"
 )
 
 const ModbusBool string = "bool"
@@ -12,7 +15,21 @@ const ModbusInt16 string = "s16"
 const ModbusUint16 string = "u16"
 const ModbusUint32 string = "u32"
 const ModbusInt32 string = "s32"
+const ModbusUint64 string = "u64"
+const ModbusInt64 string = "s64"
+const ModbusFloat32 string = "float32"
+const ModbusFloat64 string = "float64"
 const ModbusIEEE32 string = "ieee32"
+const ModbusString string = "string"
+const ModbusBits string = "bits"
+const ModbusEnum string = "enum"
+
+// Word and byte orders for values that span more than one register. The
+// letters give the order the bytes of a big endian value are received in.
+const OrderABCD string = "ABCD"
+const OrderCDAB string = "CDAB"
+const OrderBADC string = "BADC"
+const OrderDCBA string = "DCBA"
 
 const ModbusCoil int = 1
 const ModbusInput int = 2
@@ -22,47 +39,103 @@ type ModbusDevice struct {
 	Name      string
 	USBDevice string
 	SlaveID   byte
+	Interval  time.Duration
 
 	registers []*register
 	calls     []*registerCall
 
-	handler *modbus.RTUClientHandler
-	stopper chan bool
+	bus       *Bus
+	stopper   chan bool
+	done      chan bool
+	pipeline  *reading.Pipeline
+	lastError error
 }
 
 func NewModbusDeviceLocal(name string, usbdev string, id byte) *ModbusDevice {
-	dev := ModbusDevice{Name: name, USBDevice: usbdev, SlaveID: id}
-	dev.handler = modbus.NewRTUClientHandler(usbdev)
-	dev.handler.SlaveId = id
+	dev := ModbusDevice{Name: name, USBDevice: usbdev, SlaveID: id, Interval: 5 * time.Second}
+	dev.bus = GetBus(usbdev)
 	return &dev
 }
 
 func (md *ModbusDevice) SetSerial(spd int) {
-	md.handler.BaudRate = spd
+	md.bus.SetSerial(spd)
+}
+
+// SetFrameDelay sets the minimum gap between transactions on the bus used by
+// the device. This applies to every device sharing the bus.
+func (md *ModbusDevice) SetFrameDelay(delay time.Duration) {
+	md.bus.SetFrameDelay(delay)
+}
+
+func (md *ModbusDevice) SetPipeline(p *reading.Pipeline) {
+	md.pipeline = p
+}
+
+// RegisterSpec describes a register to be read. The value reported is
+//
+//	raw * Multiplier / 10^Factor + Offset
+//
+// where a Multiplier of 0 is treated as 1. Values without any scaling are
+// reported using their natural integer type. This scaling describes how the
+// device encodes the value. Calibration of the sensor is done afterwards by
+// the reading pipeline, as for every other device.
+//
+// Interval is how often the register is read. If 0 the device interval is
+// used. Registers are only batched into a single read with registers that
+// share the same interval.
+//
+// Length gives the number of registers used by a string, or for bits and
+// enum registers 2 to use a 32 bit value. Labels names each bit of a bits
+// register, or each value of an enum register.
+type RegisterSpec struct {
+	Description string
+	Tag         string
+	Register    uint16
+	Format      string
+	Type        int
+	Factor      int
+	Multiplier  float64
+	Offset      float64
+	Order       string
+	Length      uint16
+	Labels      map[int]string
+	Interval    time.Duration
 }
 
-func (md *ModbusDevice) AddRegister(desc, units string, regno uint16, format string, factor uint16, typ int, offset int) {
-	reg := newRegister(desc, units, regno, format, factor, typ, offset)
+func (md *ModbusDevice) AddRegister(spec RegisterSpec) error {
+	reg, err := newRegister(spec)
+	if err != nil {
+		return err
+	}
 	md.registers = append(md.registers, reg)
 
 	var found bool
 	for _, call := range md.calls {
-		if call.qty == 125 {
+		if reg.typ != call.typ || reg.interval != call.interval {
 			continue
 		}
-		if reg.typ != call.typ {
+		if reg.register > call.end || reg.endRegister() < call.start {
 			continue
 		}
-		if (call.start <= regno && call.end >= regno) || call.end == regno {
-			call.addRegister(reg)
-			found = true
-			break
+		if max(call.end, reg.endRegister())-min(call.start, reg.register) > 125 {
+			continue
 		}
+		call.addRegister(reg)
+		found = true
+		break
 	}
 	if !found {
-		rcall := registerCall{reg.typ, reg.register, reg.endRegister(), reg.nRegisters, []*register{reg}}
+		rcall := registerCall{
+			typ:       reg.typ,
+			start:     reg.register,
+			end:       reg.endRegister(),
+			qty:       reg.nRegisters,
+			interval:  reg.interval,
+			registers: []*register{reg},
+		}
 		md.calls = append(md.calls, &rcall)
 	}
+	return nil
 }
 
 /*
@@ -80,38 +153,75 @@ func (md *ModbusDevice) ShowData() {
 }
 */
 
+func (md *ModbusDevice) callInterval(call *registerCall) time.Duration {
+	if call.interval > 0 {
+		return call.interval
+	}
+	return md.Interval
+}
+
+// ReadOnce reads every register.
 func (md *ModbusDevice) ReadOnce() error {
-	err := md.handler.Connect()
-	if err != nil {
-		log.Println(err)
-		return err
+	now := time.Now()
+	for _, call := range md.calls {
+		call.nextRead = now.Add(md.callInterval(call))
 	}
-	defer md.handler.Close()
+	return md.readCalls(md.calls)
+}
 
-	client := modbus.NewClient(md.handler)
+// ReadDue reads the registers that are due to be read, those with the
+// shortest interval first.
+func (md *ModbusDevice) ReadDue(now time.Time) error {
+	var due []*registerCall
+	for _, call := range md.calls {
+		// allow for the ticker firing slightly early
+		if call.nextRead.Sub(now) < 500*time.Millisecond {
+			due = append(due, call)
+			call.nextRead = now.Add(md.callInterval(call))
+		}
+	}
+	if len(due) == 0 {
+		return nil
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return md.callInterval(due[i]) < md.callInterval(due[j])
+	})
+	return md.readCalls(due)
+}
 
+func (md *ModbusDevice) readCalls(calls []*registerCall) error {
 	readCompleted := 0
-	for n, call := range md.calls {
+	for n, call := range calls {
 		var data []byte
-		var err error
-		switch call.typ {
-		case ModbusHolding:
-			data, err = client.ReadHoldingRegisters(call.start, call.qty)
-		case ModbusInput:
-			data, err = client.ReadInputRegisters(call.start, call.qty)
-		}
+		err := md.bus.Transaction(md.SlaveID, func(client modbus.Client) error {
+			var err error
+			switch call.typ {
+			case ModbusHolding:
+				data, err = client.ReadHoldingRegisters(call.start, call.qty)
+			case ModbusInput:
+				data, err = client.ReadInputRegisters(call.start, call.qty)
+			}
+			return err
+		})
 
 		if err != nil {
-			log.Printf("unable to get data for call #%d: %s", n, err)
+			log.Printf("%s: unable to get data for call #%d: %s", md.Name, n, err)
 			continue
 		}
 		readCompleted++
 		call.processData(data)
+		for _, reg := range call.registers {
+			if v, ok := reading.Float(reg.getValue()); ok {
+				md.pipeline.Process(reg.tag, v)
+			}
+		}
 	}
 	if readCompleted == 0 {
 		log.Printf("Unable to read any data from %s", md.USBDevice)
-		return fmt.Errorf("failed to read data")
+		md.lastError = fmt.Errorf("failed to read data")
+		return md.lastError
 	}
+	md.lastError = nil
 	return nil
 }
 
@@ -126,7 +236,12 @@ func (md *ModbusDevice) JsonResponse() map[string]interface{} {
 			log.Printf("unable to get data for register %s [%s]", reg.description, reg.tag)
 			continue
 		}
-		rv[reg.tag] = reg.getValue()
+		rv[reg.tag] = v
+		reg.addLabels(rv, v)
+	}
+	md.pipeline.Apply(rv)
+	if md.lastError != nil {
+		rv["error"] = md.lastError.Error()
 	}
 	return rv
 }
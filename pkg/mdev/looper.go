@@ -5,41 +5,78 @@ import (
 	"time"
 )
 
-var interval time.Duration
+// tickInterval returns the largest period that every register interval is a
+// multiple of, so that the loop wakes up whenever any register is due.
+func (md *ModbusDevice) tickInterval() time.Duration {
+	gcd := func(a, b time.Duration) time.Duration {
+		for b != 0 {
+			a, b = b, a%b
+		}
+		return a
+	}
+	tick := md.Interval
+	for _, call := range md.calls {
+		tick = gcd(tick, md.callInterval(call))
+	}
+	if tick < time.Second {
+		tick = time.Second
+	}
+	return tick
+}
 
 func (md *ModbusDevice) Start(intval int) {
-	interval = time.Duration(intval) * time.Second
-	if md.stopper != nil {
-		md.stopper <- true
+	if intval > 0 {
+		md.Interval = time.Duration(intval) * time.Second
 	}
-	md.stopper = make(chan bool, 1)
+	md.Stop()
+	stopper := make(chan bool, 1)
+	done := make(chan bool)
+	md.stopper, md.done = stopper, done
 
 	go func() {
-		ticker := time.NewTicker(interval)
+		ticker := time.NewTicker(md.tickInterval())
 		errors := 0
 	TickerLoop:
 		for {
 			select {
-			case <-ticker.C:
-				if err := md.ReadOnce(); err != nil {
+			case now := <-ticker.C:
+				if err := md.ReadDue(now); err != nil {
 					errors++
 					if errors > 5 {
 						log.Printf("unable to read data repeatedly. Aborting collection loop")
 						break TickerLoop
 					}
+				} else {
+					errors = 0
 				}
-			case <-md.stopper:
+			case <-stopper:
 				break TickerLoop
 			}
 		}
 		ticker.Stop()
-		md.stopper = nil
+		close(done)
 	}()
 }
 
+// Close stops collection and releases the serial bus used by the device.
+func (md *ModbusDevice) Close() {
+	md.Stop()
+	if md.bus != nil {
+		md.bus.Release()
+		md.bus = nil
+	}
+}
+
+// Stop asks the read loop to exit and waits for it to do so, so no request
+// is in progress once it returns.
 func (md *ModbusDevice) Stop() {
 	if md.stopper == nil {
 		return
 	}
-	md.stopper <- true
+	select {
+	case md.stopper <- true:
+	default:
+	}
+	<-md.done
+	md.stopper, md.done = nil, nil
 }
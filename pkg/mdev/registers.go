@@ -2,8 +2,11 @@ package mdev
 
 import (
 	"encoding/binary"
+	"fmt"
 	"math"
 	"sort"
+	"strings"
+	"time"
 )
 
 type register struct {
@@ -11,9 +14,14 @@ type register struct {
 	tag         string
 	register    uint16
 	format      string
-	factor      uint16
 	typ         int
-	offset      int
+	factor      int
+	multiplier  float64
+	offset      float64
+	wordSwap    bool
+	byteSwap    bool
+	labels      map[int]string
+	interval    time.Duration
 
 	nRegisters uint16
 	nBytes     int
@@ -26,22 +34,71 @@ type registerCall struct {
 	start     uint16
 	end       uint16
 	qty       uint16
+	interval  time.Duration
+	nextRead  time.Time
 	registers []*register
 }
 
-func newRegister(desc, tag string, regno uint16, format string, factor uint16, typ int, offset int) *register {
-	reg := register{description: desc, tag: tag, register: regno, format: format, factor: factor, typ: typ, offset: offset, nRegisters: 1, nBytes: 2}
+func newRegister(spec RegisterSpec) (*register, error) {
+	reg := register{
+		description: spec.Description,
+		tag:         spec.Tag,
+		register:    spec.Register,
+		format:      strings.ToLower(spec.Format),
+		typ:         spec.Type,
+		factor:      spec.Factor,
+		multiplier:  spec.Multiplier,
+		offset:      spec.Offset,
+		labels:      spec.Labels,
+		interval:    spec.Interval,
+		nRegisters:  1,
+		nBytes:      2,
+	}
 
-	switch format {
+	switch reg.format {
 	case ModbusBool:
 		if reg.typ == ModbusCoil {
 			reg.nBytes = 1
 		}
-	case ModbusUint32, ModbusInt32, ModbusIEEE32:
+	case ModbusInt16, ModbusUint16:
+	case ModbusUint32, ModbusInt32, ModbusFloat32, ModbusIEEE32:
 		reg.nRegisters = 2
 		reg.nBytes = 4
+	case ModbusUint64, ModbusInt64, ModbusFloat64:
+		reg.nRegisters = 4
+		reg.nBytes = 8
+	case ModbusString:
+		if spec.Length == 0 || spec.Length > 125 {
+			return nil, fmt.Errorf("register %d [%s]: string length must be 1 - 125 registers", spec.Register, spec.Tag)
+		}
+		reg.nRegisters = spec.Length
+		reg.nBytes = int(spec.Length) * 2
+	case ModbusBits, ModbusEnum:
+		switch spec.Length {
+		case 0, 1:
+		case 2:
+			reg.nRegisters = 2
+			reg.nBytes = 4
+		default:
+			return nil, fmt.Errorf("register %d [%s]: %s registers must have a length of 1 or 2", spec.Register, spec.Tag, reg.format)
+		}
+	default:
+		return nil, fmt.Errorf("register %d [%s]: unknown format '%s'", spec.Register, spec.Tag, spec.Format)
+	}
+
+	switch strings.ToUpper(spec.Order) {
+	case "", OrderABCD:
+	case OrderCDAB:
+		reg.wordSwap = true
+	case OrderBADC:
+		reg.byteSwap = true
+	case OrderDCBA:
+		reg.wordSwap = true
+		reg.byteSwap = true
+	default:
+		return nil, fmt.Errorf("register %d [%s]: unknown word order '%s'", spec.Register, spec.Tag, spec.Order)
 	}
-	return &reg
+	return &reg, nil
 }
 
 func (r register) endRegister() uint16 {
@@ -65,73 +122,128 @@ func (rc *registerCall) addRegister(reg *register) {
 }
 
 func (rc *registerCall) processData(data []byte) {
-	pos := 0
 	for _, reg := range rc.registers {
+		pos := int(reg.register-rc.start) * 2
+		if pos+reg.nBytes > len(data) {
+			continue
+		}
 		reg.rawValue = data[pos : pos+reg.nBytes]
-		pos += reg.nBytes
 	}
 }
 
+// ordered returns the raw bytes rearranged into big endian order.
+func (r *register) ordered() []byte {
+	if len(r.rawValue) < 2 || (!r.wordSwap && !r.byteSwap) {
+		return r.rawValue
+	}
+	words := len(r.rawValue) / 2
+	rv := make([]byte, len(r.rawValue))
+	for n := 0; n < words; n++ {
+		src := n
+		if r.wordSwap {
+			src = words - 1 - n
+		}
+		hi, lo := r.rawValue[src*2], r.rawValue[src*2+1]
+		if r.byteSwap {
+			hi, lo = lo, hi
+		}
+		rv[n*2] = hi
+		rv[n*2+1] = lo
+	}
+	return rv
+}
+
+func (r *register) scaled() bool {
+	return r.factor != 0 || (r.multiplier != 0 && r.multiplier != 1) || r.offset != 0
+}
+
+func (r *register) scale(v float64) float64 {
+	if r.multiplier != 0 {
+		v *= r.multiplier
+	}
+	if r.factor != 0 {
+		v /= math.Pow10(r.factor)
+	}
+	return v + r.offset
+}
+
 func (r *register) getValue() interface{} {
+	if len(r.rawValue) < r.nBytes {
+		return nil
+	}
+	data := r.ordered()
+
+	var iv interface{}
+	var fv float64
 	switch r.format {
 	case ModbusBool:
 		if r.typ == ModbusCoil {
-			return r.rawValue[0] == 1
+			return data[0] == 1
 		}
-		return r.rawValue[1] == 1
+		return data[1] == 1
 	case ModbusInt16:
-		v := int16(binary.BigEndian.Uint16(r.rawValue))
-		if r.offset != 0 {
-			v += int16(r.offset) * int16(r.factor*10)
-		}
-		if r.factor != 0 {
-			return float64(v) / float64(r.factor*10)
-		}
-		return v
+		v := int16(binary.BigEndian.Uint16(data))
+		iv, fv = v, float64(v)
 	case ModbusUint16:
-		v := binary.BigEndian.Uint16(r.rawValue)
-		if r.offset != 0 {
-			v += uint16(r.offset) * uint16(r.factor*10)
-		}
-		if r.factor != 0 {
-			return float64(v) / float64(r.factor*10)
-		}
-		return v
+		v := binary.BigEndian.Uint16(data)
+		iv, fv = v, float64(v)
 	case ModbusInt32:
-		v := int32(binary.BigEndian.Uint32(r.rawValue))
-		if r.offset != 0 {
-			v += int32(r.offset) * int32(r.factor*10)
-		}
-		if r.factor > 0 {
-			return float64(v) / float64(r.factor*10)
-		}
-		return v
+		v := int32(binary.BigEndian.Uint32(data))
+		iv, fv = v, float64(v)
 	case ModbusUint32:
-		v := binary.BigEndian.Uint32(r.rawValue)
-		if r.offset != 0 {
-			v += uint32(r.offset) * uint32(r.factor*10)
-		}
-		if r.factor > 0 {
-			return float64(v) / float64(r.factor*10)
+		v := binary.BigEndian.Uint32(data)
+		iv, fv = v, float64(v)
+	case ModbusInt64:
+		v := int64(binary.BigEndian.Uint64(data))
+		iv, fv = v, float64(v)
+	case ModbusUint64:
+		v := binary.BigEndian.Uint64(data)
+		iv, fv = v, float64(v)
+	case ModbusFloat32, ModbusIEEE32:
+		fv = float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case ModbusFloat64:
+		fv = math.Float64frombits(binary.BigEndian.Uint64(data))
+	case ModbusString:
+		return strings.TrimRight(strings.TrimRight(string(data), "\x00"), " ")
+	case ModbusBits, ModbusEnum:
+		if r.nBytes == 4 {
+			return binary.BigEndian.Uint32(data)
 		}
-		return v
-	case ModbusIEEE32:
-		u := binary.BigEndian.Uint32(r.rawValue)
-		sign := u >> 31
-		exp := float64(u>>23&0xff) - 0x7f
-		rem := uint64(u & 0x7fffff)
-		var bottom uint64
-		if exp != 0 {
-			bottom = 0x800000
-		} else {
-			bottom = 0x400000
+		return uint32(binary.BigEndian.Uint16(data))
+	default:
+		return nil
+	}
+	if iv == nil {
+		// NaN and infinity can not be represented in JSON
+		if math.IsNaN(fv) || math.IsInf(fv, 0) {
+			return nil
 		}
-		mant := float64(rem)/float64(bottom) + 1
+	} else if !r.scaled() {
+		return iv
+	}
+	return r.scale(fv)
+}
 
-		if sign == 0 {
-			return mant * math.Exp2(exp)
+// addLabels adds the named values for bits and enum registers, i.e.
+// <tag>_<name> for each bit and <tag>_label for an enum.
+func (r *register) addLabels(rv map[string]interface{}, v interface{}) {
+	raw, ok := v.(uint32)
+	if !ok {
+		return
+	}
+	switch r.format {
+	case ModbusBits:
+		for bit, name := range r.labels {
+			if bit < 0 || bit >= r.nBytes*8 {
+				continue
+			}
+			rv[r.tag+"_"+name] = raw&(1<<bit) != 0
+		}
+	case ModbusEnum:
+		label, ck := r.labels[int(raw)]
+		if !ck {
+			label = fmt.Sprintf("unknown (%d)", raw)
 		}
-		return -1 * mant * math.Exp2(exp)
+		rv[r.tag+"_label"] = label
 	}
-	return nil
 }
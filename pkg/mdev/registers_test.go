@@ -0,0 +1,204 @@
+package mdev
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func decodeRegister(t *testing.T, spec RegisterSpec, raw []byte) interface{} {
+	t.Helper()
+	reg, err := newRegister(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.rawValue = raw
+	return reg.getValue()
+}
+
+func checkValue(t *testing.T, got, want interface{}) {
+	t.Helper()
+	if wf, ok := want.(float64); ok {
+		gf, ok := got.(float64)
+		if !ok || math.Abs(gf-wf) > 1e-9 {
+			t.Errorf("got %v (%T), expected %v (float64)", got, got, want)
+		}
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v (%T), expected %v (%T)", got, got, want, want)
+	}
+}
+
+func TestRegisterTypes(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		typ    int
+		raw    []byte
+		want   interface{}
+	}{
+		{"coil on", ModbusBool, ModbusCoil, []byte{0x01}, true},
+		{"coil off", ModbusBool, ModbusCoil, []byte{0x00}, false},
+		{"holding bool", ModbusBool, ModbusHolding, []byte{0x00, 0x01}, true},
+		{"holding bool off", ModbusBool, ModbusHolding, []byte{0x00, 0x00}, false},
+		{"u16", ModbusUint16, ModbusHolding, []byte{0x01, 0xE9}, uint16(489)},
+		{"u16 maximum", ModbusUint16, ModbusHolding, []byte{0xFF, 0xFF}, uint16(65535)},
+		{"s16 positive", ModbusInt16, ModbusHolding, []byte{0x01, 0xE9}, int16(489)},
+		{"s16 negative", ModbusInt16, ModbusHolding, []byte{0xFF, 0xFE}, int16(-2)},
+		{"u32", ModbusUint32, ModbusHolding, []byte{0x00, 0x01, 0x00, 0x02}, uint32(65538)},
+		{"s32 negative", ModbusInt32, ModbusHolding, []byte{0xFF, 0xFF, 0xFF, 0xFE}, int32(-2)},
+		{"u64", ModbusUint64, ModbusHolding, []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}, uint64(0x0102030405060708)},
+		{"u64 maximum", ModbusUint64, ModbusHolding, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, uint64(math.MaxUint64)},
+		{"s64 negative", ModbusInt64, ModbusHolding, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFE}, int64(-2)},
+		{"float32", ModbusFloat32, ModbusHolding, []byte{0x3F, 0xC0, 0x00, 0x00}, 1.5},
+		{"ieee32", ModbusIEEE32, ModbusInput, []byte{0xC1, 0x48, 0x00, 0x00}, -12.5},
+		{"float64", ModbusFloat64, ModbusHolding, []byte{0x3F, 0xF8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 1.5},
+		{"float32 NaN", ModbusFloat32, ModbusHolding, []byte{0x7F, 0xC0, 0x00, 0x00}, nil},
+		{"float64 infinity", ModbusFloat64, ModbusHolding, []byte{0x7F, 0xF0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, nil},
+		{"short data", ModbusUint32, ModbusHolding, []byte{0x00, 0x01}, nil},
+		{"no data", ModbusUint16, ModbusHolding, nil, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := decodeRegister(t, RegisterSpec{Tag: "t", Format: tc.format, Type: tc.typ}, tc.raw)
+			checkValue(t, got, tc.want)
+		})
+	}
+}
+
+func TestRegisterWordOrder(t *testing.T) {
+	tests := []struct {
+		format string
+		order  string
+		raw    []byte
+		want   interface{}
+	}{
+		{ModbusUint16, OrderABCD, []byte{0x01, 0xE9}, uint16(489)},
+		{ModbusUint16, OrderCDAB, []byte{0x01, 0xE9}, uint16(489)},
+		{ModbusUint16, OrderBADC, []byte{0xE9, 0x01}, uint16(489)},
+		{ModbusUint16, OrderDCBA, []byte{0xE9, 0x01}, uint16(489)},
+
+		{ModbusUint32, "", []byte{0x11, 0x22, 0x33, 0x44}, uint32(0x11223344)},
+		{ModbusUint32, OrderABCD, []byte{0x11, 0x22, 0x33, 0x44}, uint32(0x11223344)},
+		{ModbusUint32, OrderCDAB, []byte{0x33, 0x44, 0x11, 0x22}, uint32(0x11223344)},
+		{ModbusUint32, OrderBADC, []byte{0x22, 0x11, 0x44, 0x33}, uint32(0x11223344)},
+		{ModbusUint32, OrderDCBA, []byte{0x44, 0x33, 0x22, 0x11}, uint32(0x11223344)},
+		{ModbusInt32, "cdab", []byte{0xFF, 0xFE, 0xFF, 0xFF}, int32(-2)},
+
+		{ModbusFloat32, OrderABCD, []byte{0x3F, 0xC0, 0x00, 0x00}, 1.5},
+		{ModbusFloat32, OrderCDAB, []byte{0x00, 0x00, 0x3F, 0xC0}, 1.5},
+		{ModbusFloat32, OrderBADC, []byte{0xC0, 0x3F, 0x00, 0x00}, 1.5},
+		{ModbusFloat32, OrderDCBA, []byte{0x00, 0x00, 0xC0, 0x3F}, 1.5},
+
+		{ModbusUint64, OrderABCD, []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}, uint64(0x0102030405060708)},
+		{ModbusUint64, OrderCDAB, []byte{0x07, 0x08, 0x05, 0x06, 0x03, 0x04, 0x01, 0x02}, uint64(0x0102030405060708)},
+		{ModbusUint64, OrderBADC, []byte{0x02, 0x01, 0x04, 0x03, 0x06, 0x05, 0x08, 0x07}, uint64(0x0102030405060708)},
+		{ModbusUint64, OrderDCBA, []byte{0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01}, uint64(0x0102030405060708)},
+		{ModbusInt64, OrderDCBA, []byte{0xFE, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, int64(-2)},
+
+		{ModbusFloat64, OrderABCD, []byte{0x3F, 0xF8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 1.5},
+		{ModbusFloat64, OrderCDAB, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x3F, 0xF8}, 1.5},
+		{ModbusFloat64, OrderBADC, []byte{0xF8, 0x3F, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 1.5},
+		{ModbusFloat64, OrderDCBA, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xF8, 0x3F}, 1.5},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.format+"_"+tc.order, func(t *testing.T) {
+			got := decodeRegister(t, RegisterSpec{Tag: "t", Format: tc.format, Type: ModbusHolding, Order: tc.order}, tc.raw)
+			checkValue(t, got, tc.want)
+		})
+	}
+}
+
+func TestRegisterScaling(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		raw        []byte
+		factor     int
+		multiplier float64
+		offset     float64
+		want       interface{}
+	}{
+		// values without scaling keep their integer type
+		{"unscaled u16", ModbusUint16, []byte{0x01, 0xE9}, 0, 0, 0, uint16(489)},
+		{"multiplier of 1 is unscaled", ModbusUint16, []byte{0x01, 0xE9}, 0, 1, 0, uint16(489)},
+		{"unscaled s32", ModbusInt32, []byte{0xFF, 0xFF, 0xFF, 0xFE}, 0, 0, 0, int32(-2)},
+
+		// any scaling returns a float
+		{"factor", ModbusUint16, []byte{0x01, 0xE9}, 1, 0, 0, 48.9},
+		{"factor 2", ModbusUint16, []byte{0x01, 0xE9}, 2, 0, 0, 4.89},
+		{"negative factor", ModbusUint16, []byte{0x00, 0x05}, -2, 0, 0, 500.0},
+		{"multiplier", ModbusUint16, []byte{0x01, 0xE9}, 0, 0.5, 0, 244.5},
+		{"offset", ModbusUint16, []byte{0x01, 0xE9}, 0, 0, -98, 391.0},
+		{"factor, multiplier and offset", ModbusUint16, []byte{0x01, 0xE9}, 1, 2, 1, 98.8},
+		{"negative with factor", ModbusInt16, []byte{0xFF, 0xFE}, 1, 0, 0, -0.2},
+		{"u32 with factor", ModbusUint32, []byte{0x00, 0x01, 0x00, 0x02}, 3, 0, 0, 65.538},
+		{"s64 with multiplier", ModbusInt64, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFE}, 0, 10, 0, -20.0},
+		{"float32 with offset", ModbusFloat32, []byte{0x3F, 0xC0, 0x00, 0x00}, 0, 0, 1, 2.5},
+		{"float64 with factor", ModbusFloat64, []byte{0x3F, 0xF8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 1, 0, 0, 0.15},
+		{"bool is not scaled", ModbusBool, []byte{0x00, 0x01}, 1, 2, 3, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := RegisterSpec{Tag: "t", Format: tc.format, Type: ModbusHolding,
+				Factor: tc.factor, Multiplier: tc.multiplier, Offset: tc.offset}
+			checkValue(t, decodeRegister(t, spec, tc.raw), tc.want)
+		})
+	}
+}
+
+func TestRegisterStringsAndLabels(t *testing.T) {
+	got := decodeRegister(t, RegisterSpec{Tag: "t", Format: ModbusString, Type: ModbusHolding, Length: 3},
+		[]byte{'V', '1', '.', '2', 0, 0})
+	checkValue(t, got, "V1.2")
+
+	reg, err := newRegister(RegisterSpec{Tag: "alarm", Format: ModbusBits, Type: ModbusHolding,
+		Labels: map[int]string{0: "fan", 3: "filter", 20: "ignored"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.rawValue = []byte{0x00, 0x08}
+	v := reg.getValue()
+	checkValue(t, v, uint32(8))
+	rv := map[string]interface{}{}
+	reg.addLabels(rv, v)
+	checkValue(t, rv, map[string]interface{}{"alarm_fan": false, "alarm_filter": true})
+
+	reg, err = newRegister(RegisterSpec{Tag: "mode", Format: ModbusEnum, Type: ModbusHolding, Length: 2,
+		Labels: map[int]string{1: "auto"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.rawValue = []byte{0x00, 0x00, 0x00, 0x01}
+	rv = map[string]interface{}{}
+	reg.addLabels(rv, reg.getValue())
+	checkValue(t, rv, map[string]interface{}{"mode_label": "auto"})
+	reg.rawValue = []byte{0x00, 0x00, 0x00, 0x02}
+	reg.addLabels(rv, reg.getValue())
+	checkValue(t, rv, map[string]interface{}{"mode_label": "unknown (2)"})
+}
+
+func TestRegisterSpecErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		spec RegisterSpec
+	}{
+		{"unknown format", RegisterSpec{Format: "u24"}},
+		{"missing format", RegisterSpec{}},
+		{"unknown order", RegisterSpec{Format: ModbusUint32, Order: "ACBD"}},
+		{"string without length", RegisterSpec{Format: ModbusString}},
+		{"string too long", RegisterSpec{Format: ModbusString, Length: 126}},
+		{"bits length", RegisterSpec{Format: ModbusBits, Length: 3}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := newRegister(tc.spec); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
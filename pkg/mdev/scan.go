@@ -0,0 +1,224 @@
+package mdev
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goburrow/modbus"
+)
+
+// ScanOptions controls a scan of a bus. Either Device (a serial port) or Host
+// (a modbus TCP address as host:port) must be given. If no Bauds are given a
+// serial port is scanned at 9600 and 19200 baud.
+type ScanOptions struct {
+	Device    string
+	Host      string
+	Bauds     []int
+	SlaveFrom byte
+	SlaveTo   byte
+	RegFrom   uint16
+	RegTo     uint16
+	Holding   bool
+	Input     bool
+	Timeout   time.Duration
+}
+
+// ScanSlave is a slave that responded to a probe.
+type ScanSlave struct {
+	Baud    int
+	SlaveID byte
+}
+
+// ScanResult is the response for a single register. When Exception is not 0
+// the slave returned a modbus exception rather than a value.
+type ScanResult struct {
+	Type      int
+	Register  uint16
+	Value     uint16
+	Exception byte
+}
+
+// scanHandler is the part of the RTU and TCP handlers used by the scanner.
+type scanHandler interface {
+	modbus.ClientHandler
+	Connect() error
+	Close() error
+}
+
+type scanner struct {
+	opts     ScanOptions
+	handler  scanHandler
+	setSlave func(byte)
+}
+
+func newScanner(opts ScanOptions, baud int) (*scanner, error) {
+	if opts.Timeout == 0 {
+		opts.Timeout = 500 * time.Millisecond
+	}
+	sc := scanner{opts: opts}
+	switch {
+	case opts.Host != "":
+		h := modbus.NewTCPClientHandler(opts.Host)
+		h.Timeout = opts.Timeout
+		sc.handler = h
+		sc.setSlave = func(id byte) { h.SlaveId = id }
+	case opts.Device != "":
+		h := modbus.NewRTUClientHandler(opts.Device)
+		h.BaudRate = baud
+		h.Timeout = opts.Timeout
+		sc.handler = h
+		sc.setSlave = func(id byte) { h.SlaveId = id }
+	default:
+		return nil, fmt.Errorf("a serial device or TCP host is required")
+	}
+	if err := sc.handler.Connect(); err != nil {
+		return nil, fmt.Errorf("unable to connect to %s%s: %w", opts.Host, opts.Device, err)
+	}
+	return &sc, nil
+}
+
+// exceptionCode returns the modbus exception code for err, or 0 if err is
+// not a modbus exception.
+func exceptionCode(err error) byte {
+	var mbErr *modbus.ModbusError
+	if errors.As(err, &mbErr) {
+		return mbErr.ExceptionCode
+	}
+	return 0
+}
+
+func (sc *scanner) read(typ int, start, qty uint16) ([]byte, error) {
+	client := modbus.NewClient(sc.handler)
+	if typ == ModbusInput {
+		return client.ReadInputRegisters(start, qty)
+	}
+	return client.ReadHoldingRegisters(start, qty)
+}
+
+// probe returns true if the slave sends any response, including an
+// exception, to a read of the first register.
+func (sc *scanner) probe(id byte) bool {
+	sc.setSlave(id)
+	typ := ModbusHolding
+	if !sc.opts.Holding && sc.opts.Input {
+		typ = ModbusInput
+	}
+	_, err := sc.read(typ, sc.opts.RegFrom, 1)
+	return err == nil || exceptionCode(err) != 0
+}
+
+// registers reads the register range in blocks, falling back to reading one
+// register at a time when a block fails.
+func (sc *scanner) registers(id byte, typ int) []ScanResult {
+	sc.setSlave(id)
+	var results []ScanResult
+	for start := int(sc.opts.RegFrom); start <= int(sc.opts.RegTo); start += 16 {
+		qty := min(16, int(sc.opts.RegTo)-start+1)
+		data, err := sc.read(typ, uint16(start), uint16(qty))
+		if err == nil && len(data) == qty*2 {
+			for n := 0; n < qty; n++ {
+				results = append(results, ScanResult{Type: typ, Register: uint16(start + n), Value: uint16(data[n*2])<<8 | uint16(data[n*2+1])})
+			}
+			continue
+		}
+		for n := 0; n < qty; n++ {
+			reg := uint16(start + n)
+			data, err := sc.read(typ, reg, 1)
+			switch {
+			case err == nil && len(data) == 2:
+				results = append(results, ScanResult{Type: typ, Register: reg, Value: uint16(data[0])<<8 | uint16(data[1])})
+			case exceptionCode(err) != 0:
+				results = append(results, ScanResult{Type: typ, Register: reg, Exception: exceptionCode(err)})
+			}
+		}
+	}
+	return results
+}
+
+// The baud rates tried on a serial port if none are given.
+var defaultScanBauds = []int{9600, 19200}
+
+// scanBauds returns the baud rates to scan. TCP has no baud rate, so is
+// scanned once with a rate of 0.
+func scanBauds(opts ScanOptions) []int {
+	if opts.Host != "" {
+		return []int{0}
+	}
+	if len(opts.Bauds) == 0 {
+		return defaultScanBauds
+	}
+	return opts.Bauds
+}
+
+// ScanSlaves probes each slave ID in the range at each baud rate and returns
+// those that respond. For TCP the baud rates are ignored.
+func ScanSlaves(opts ScanOptions) ([]ScanSlave, error) {
+	var found []ScanSlave
+	for _, baud := range scanBauds(opts) {
+		sc, err := newScanner(opts, baud)
+		if err != nil {
+			return found, err
+		}
+		for id := int(opts.SlaveFrom); id <= int(opts.SlaveTo); id++ {
+			if sc.probe(byte(id)) {
+				found = append(found, ScanSlave{Baud: baud, SlaveID: byte(id)})
+			}
+		}
+		sc.handler.Close()
+	}
+	return found, nil
+}
+
+// ScanRegisters reads the configured register ranges from a single slave and
+// returns the registers that responded with a value or an exception.
+func ScanRegisters(opts ScanOptions, slave ScanSlave) ([]ScanResult, error) {
+	sc, err := newScanner(opts, slave.Baud)
+	if err != nil {
+		return nil, err
+	}
+	defer sc.handler.Close()
+
+	var results []ScanResult
+	if opts.Holding {
+		results = append(results, sc.registers(slave.SlaveID, ModbusHolding)...)
+	}
+	if opts.Input {
+		results = append(results, sc.registers(slave.SlaveID, ModbusInput)...)
+	}
+	return results, nil
+}
+
+// ScanYAML returns a starter registers block, in the configuration file
+// format, for the registers that returned a value. Every register is given
+// as a u16 and the descriptions and tags should be edited to suit.
+func ScanYAML(results []ScanResult) string {
+	var sb strings.Builder
+	sb.WriteString("    registers:\n")
+	for _, typ := range []int{ModbusHolding, ModbusInput} {
+		header := false
+		prefix := "H"
+		if typ == ModbusInput {
+			prefix = "I"
+		}
+		for _, res := range results {
+			if res.Type != typ || res.Exception != 0 {
+				continue
+			}
+			if !header {
+				if typ == ModbusHolding {
+					sb.WriteString("      holding:\n")
+				} else {
+					sb.WriteString("      input:\n")
+				}
+				header = true
+			}
+			fmt.Fprintf(&sb, "        - description: \"Register %d\"\n", res.Register)
+			fmt.Fprintf(&sb, "          tag: \"%s%d\"\n", prefix, res.Register)
+			fmt.Fprintf(&sb, "          register: %d\n", res.Register)
+			sb.WriteString("          typ: \"u16\"\n")
+		}
+	}
+	return sb.String()
+}
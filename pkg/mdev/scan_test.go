@@ -0,0 +1,160 @@
+package mdev
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goburrow/modbus"
+)
+
+// fakeBus answers register reads from the values held for each slave. A
+// read including a register that is not held returns an illegal address
+// exception, and reads from unknown slaves time out.
+type fakeBus struct {
+	slave  byte
+	values map[byte]map[uint16]uint16
+	reads  int
+}
+
+func (fb *fakeBus) Connect() error { return nil }
+func (fb *fakeBus) Close() error   { return nil }
+
+func (fb *fakeBus) Encode(pdu *modbus.ProtocolDataUnit) ([]byte, error) {
+	return append([]byte{fb.slave, pdu.FunctionCode}, pdu.Data...), nil
+}
+
+func (fb *fakeBus) Decode(adu []byte) (*modbus.ProtocolDataUnit, error) {
+	return &modbus.ProtocolDataUnit{FunctionCode: adu[1], Data: adu[2:]}, nil
+}
+
+func (fb *fakeBus) Verify(request, response []byte) error { return nil }
+
+func (fb *fakeBus) Send(request []byte) ([]byte, error) {
+	fb.reads++
+	regs, ck := fb.values[request[0]]
+	if !ck {
+		return nil, errors.New("timeout")
+	}
+	start := binary.BigEndian.Uint16(request[2:])
+	qty := binary.BigEndian.Uint16(request[4:])
+	rv := []byte{request[0], request[1], byte(qty * 2)}
+	for reg := start; reg < start+qty; reg++ {
+		v, ck := regs[reg]
+		if !ck {
+			return []byte{request[0], request[1] | 0x80, modbus.ExceptionCodeIllegalDataAddress}, nil
+		}
+		rv = binary.BigEndian.AppendUint16(rv, v)
+	}
+	return rv, nil
+}
+
+func newFakeScanner(opts ScanOptions, values map[byte]map[uint16]uint16) (*scanner, *fakeBus) {
+	fb := &fakeBus{values: values}
+	return &scanner{opts: opts, handler: fb, setSlave: func(id byte) { fb.slave = id }}, fb
+}
+
+func TestScanProbe(t *testing.T) {
+	sc, _ := newFakeScanner(ScanOptions{Holding: true, RegFrom: 10}, map[byte]map[uint16]uint16{
+		1: {10: 1},
+		// a slave without the register still responds with an exception
+		2: {},
+	})
+	for id, want := range map[byte]bool{1: true, 2: true, 3: false} {
+		if got := sc.probe(id); got != want {
+			t.Errorf("slave %d: probe %v, expected %v", id, got, want)
+		}
+	}
+}
+
+func TestScanRegisters(t *testing.T) {
+	values := map[uint16]uint16{}
+	for reg := uint16(0); reg < 40; reg++ {
+		values[reg] = 1000 + reg
+	}
+	delete(values, 20)
+	sc, fb := newFakeScanner(ScanOptions{RegFrom: 0, RegTo: 39}, map[byte]map[uint16]uint16{7: values})
+
+	results := sc.registers(7, ModbusInput)
+	if len(results) != 40 {
+		t.Fatalf("%d results, expected 40", len(results))
+	}
+	for n, res := range results {
+		want := ScanResult{Type: ModbusInput, Register: uint16(n), Value: 1000 + uint16(n)}
+		if n == 20 {
+			want = ScanResult{Type: ModbusInput, Register: 20, Exception: modbus.ExceptionCodeIllegalDataAddress}
+		}
+		if res != want {
+			t.Errorf("result %d: %+v, expected %+v", n, res, want)
+		}
+	}
+	// three blocks, the second failing and being read a register at a time
+	if fb.reads != 3+16 {
+		t.Errorf("%d reads, expected %d", fb.reads, 3+16)
+	}
+
+	// registers that time out are left out
+	if results := sc.registers(8, ModbusHolding); len(results) != 0 {
+		t.Errorf("got %v from a slave that does not respond", results)
+	}
+}
+
+func TestScanBauds(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ScanOptions
+		want []int
+	}{
+		{"serial default", ScanOptions{Device: "/dev/ttyUSB0"}, []int{9600, 19200}},
+		{"serial", ScanOptions{Device: "/dev/ttyUSB0", Bauds: []int{4800}}, []int{4800}},
+		{"tcp", ScanOptions{Host: "localhost:502", Bauds: []int{4800}}, []int{0}},
+	}
+	for _, tc := range tests {
+		if got := scanBauds(tc.opts); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, expected %v", tc.name, got, tc.want)
+		}
+	}
+	if _, err := ScanSlaves(ScanOptions{SlaveFrom: 1, SlaveTo: 1}); err == nil {
+		t.Error("expected an error without a device or host")
+	}
+}
+
+func TestExceptionCode(t *testing.T) {
+	if code := exceptionCode(&modbus.ModbusError{FunctionCode: 0x83, ExceptionCode: 2}); code != 2 {
+		t.Errorf("got %d, expected 2", code)
+	}
+	if code := exceptionCode(errors.New("timeout")); code != 0 {
+		t.Errorf("got %d, expected 0", code)
+	}
+	if code := exceptionCode(nil); code != 0 {
+		t.Errorf("got %d for no error", code)
+	}
+}
+
+func TestScanYAML(t *testing.T) {
+	results := []ScanResult{
+		{Type: ModbusHolding, Register: 3, Value: 10},
+		{Type: ModbusHolding, Register: 4, Exception: 2},
+		{Type: ModbusInput, Register: 100, Value: 1},
+	}
+	want := `    registers:
+      holding:
+        - description: "Register 3"
+          tag: "H3"
+          register: 3
+          typ: "u16"
+      input:
+        - description: "Register 100"
+          tag: "I100"
+          register: 100
+          typ: "u16"
+`
+	if got := ScanYAML(results); got != want {
+		t.Errorf("got\n%s\nexpected\n%s", got, want)
+	}
+	if got := ScanYAML(results[1:2]); strings.Contains(got, "holding") {
+		t.Errorf("a block was added for a register with an exception:\n%s", got)
+	}
+}
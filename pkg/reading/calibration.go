@@ -0,0 +1,72 @@
+package reading
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Linear applies v * gain + offset.
+type Linear struct {
+	gain   float64
+	offset float64
+}
+
+func NewLinear(gain, offset float64) *Linear {
+	return &Linear{gain, offset}
+}
+
+func (ln *Linear) Apply(v float64, ts time.Time) (float64, error) {
+	return v*ln.gain + ln.offset, nil
+}
+
+// Polynomial applies c0 + c1 * v + c2 * v^2 + ...
+type Polynomial struct {
+	coefficients []float64
+}
+
+func NewPolynomial(coefficients []float64) (*Polynomial, error) {
+	if len(coefficients) == 0 {
+		return nil, fmt.Errorf("polynomial requires at least one coefficient")
+	}
+	return &Polynomial{coefficients}, nil
+}
+
+func (pl *Polynomial) Apply(v float64, ts time.Time) (float64, error) {
+	var rv float64
+	for n := len(pl.coefficients) - 1; n >= 0; n-- {
+		rv = rv*v + pl.coefficients[n]
+	}
+	return rv, nil
+}
+
+// Table maps values using linear interpolation between calibration points.
+// Values outside the table are extrapolated from the nearest two points.
+type Table struct {
+	points [][2]float64
+}
+
+func NewTable(points [][2]float64) (*Table, error) {
+	if len(points) < 2 {
+		return nil, fmt.Errorf("lookup table requires at least two points")
+	}
+	sorted := append([][2]float64(nil), points...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i][0] < sorted[j][0] })
+	for n := 1; n < len(sorted); n++ {
+		if sorted[n][0] == sorted[n-1][0] {
+			return nil, fmt.Errorf("lookup table has more than one point for %v", sorted[n][0])
+		}
+	}
+	return &Table{sorted}, nil
+}
+
+func (tb *Table) Apply(v float64, ts time.Time) (float64, error) {
+	n := sort.Search(len(tb.points), func(i int) bool { return tb.points[i][0] >= v })
+	if n == 0 {
+		n = 1
+	} else if n == len(tb.points) {
+		n = len(tb.points) - 1
+	}
+	lo, hi := tb.points[n-1], tb.points[n]
+	return lo[1] + (v-lo[0])*(hi[1]-lo[1])/(hi[0]-lo[0]), nil
+}
@@ -0,0 +1,67 @@
+package reading
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCalibration(t *testing.T) {
+	table, err := NewTable([][2]float64{{100, 10}, {0, 0}, {50, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	poly, err := NewPolynomial([]float64{1, 2, 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	constant, err := NewPolynomial([]float64{4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		stage Stage
+		in    float64
+		out   float64
+	}{
+		{"offset", NewLinear(1, -1.5), 20, 18.5},
+		{"scale", NewLinear(0.1, 0), 235, 23.5},
+		{"scale and offset", NewLinear(2, 3), 10, 23},
+		{"negative scale", NewLinear(-1, 0), 10, -10},
+		{"polynomial", poly, 2, 7},
+		{"polynomial at zero", poly, 0, 1},
+		{"polynomial negative", poly, -4, 1},
+		{"polynomial constant", constant, 123, 4},
+		{"table point", table, 50, 2},
+		{"table first point", table, 0, 0},
+		{"table last point", table, 100, 10},
+		{"table interpolated", table, 25, 1},
+		{"table interpolated upper", table, 75, 6},
+		{"table below first point", table, -10, -0.4},
+		{"table above last point", table, 120, 13.2},
+	}
+	for _, tc := range tests {
+		got, err := tc.stage.Apply(tc.in, time.Now())
+		if err != nil {
+			t.Errorf("%s: %s", tc.name, err)
+			continue
+		}
+		if math.Abs(got-tc.out) > 1e-9 {
+			t.Errorf("%s: %v gave %v, expected %v", tc.name, tc.in, got, tc.out)
+		}
+	}
+}
+
+func TestCalibrationErrors(t *testing.T) {
+	if _, err := NewPolynomial(nil); err == nil {
+		t.Error("expected an error for a polynomial without coefficients")
+	}
+	if _, err := NewTable([][2]float64{{0, 0}}); err == nil {
+		t.Error("expected an error for a table with one point")
+	}
+	if _, err := NewTable([][2]float64{{0, 0}, {10, 1}, {0, 2}}); err == nil {
+		t.Error("expected an error for a table with a repeated point")
+	}
+}
@@ -0,0 +1,169 @@
+package reading
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// MovingAverage returns the mean of the last N samples.
+type MovingAverage struct {
+	window  int
+	samples []float64
+}
+
+func NewMovingAverage(window int) (*MovingAverage, error) {
+	if window < 1 {
+		return nil, fmt.Errorf("moving average window must be at least 1")
+	}
+	return &MovingAverage{window: window}, nil
+}
+
+func (ma *MovingAverage) Apply(v float64, ts time.Time) (float64, error) {
+	ma.samples = append(ma.samples, v)
+	if len(ma.samples) > ma.window {
+		ma.samples = ma.samples[1:]
+	}
+	var sum float64
+	for _, s := range ma.samples {
+		sum += s
+	}
+	return sum / float64(len(ma.samples)), nil
+}
+
+// Exponential smooths samples using s = alpha * v + (1 - alpha) * s.
+type Exponential struct {
+	alpha  float64
+	value  float64
+	primed bool
+}
+
+func NewExponential(alpha float64) (*Exponential, error) {
+	if alpha <= 0 || alpha > 1 {
+		return nil, fmt.Errorf("exponential smoothing alpha must be between 0 and 1")
+	}
+	return &Exponential{alpha: alpha}, nil
+}
+
+func (ex *Exponential) Apply(v float64, ts time.Time) (float64, error) {
+	if !ex.primed {
+		ex.value = v
+		ex.primed = true
+		return v, nil
+	}
+	ex.value = ex.alpha*v + (1-ex.alpha)*ex.value
+	return ex.value, nil
+}
+
+// Median returns the median of the last N samples.
+type Median struct {
+	window  int
+	samples []float64
+}
+
+func NewMedian(window int) (*Median, error) {
+	if window < 1 {
+		return nil, fmt.Errorf("median window must be at least 1")
+	}
+	return &Median{window: window}, nil
+}
+
+func (md *Median) Apply(v float64, ts time.Time) (float64, error) {
+	md.samples = append(md.samples, v)
+	if len(md.samples) > md.window {
+		md.samples = md.samples[1:]
+	}
+	sorted := append([]float64(nil), md.samples...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2], nil
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2, nil
+}
+
+// SpikeReject drops samples that change faster than the maximum rate (units
+// per second) from the last accepted sample. After maxRejects consecutive
+// rejections the new level is accepted, so a genuine step change is not
+// ignored for ever.
+type SpikeReject struct {
+	maxRate    float64
+	maxRejects int
+	last       float64
+	lastTime   time.Time
+	rejected   int
+}
+
+func NewSpikeReject(maxRate float64, maxRejects int) (*SpikeReject, error) {
+	if maxRate <= 0 {
+		return nil, fmt.Errorf("spike rejection requires a positive maximum rate")
+	}
+	if maxRejects <= 0 {
+		maxRejects = 3
+	}
+	return &SpikeReject{maxRate: maxRate, maxRejects: maxRejects}, nil
+}
+
+func (sr *SpikeReject) Apply(v float64, ts time.Time) (float64, error) {
+	if !sr.lastTime.IsZero() {
+		dt := ts.Sub(sr.lastTime).Seconds()
+		if dt <= 0 {
+			dt = 1
+		}
+		if math.Abs(v-sr.last)/dt > sr.maxRate && sr.rejected < sr.maxRejects {
+			sr.rejected++
+			return sr.last, ErrRejected
+		}
+	}
+	sr.last = v
+	sr.lastTime = ts
+	sr.rejected = 0
+	return v, nil
+}
+
+// Clamp limits samples to the range min - max. Either limit may be nil.
+type Clamp struct {
+	min *float64
+	max *float64
+}
+
+func NewClamp(min, max *float64) (*Clamp, error) {
+	if min != nil && max != nil && *min > *max {
+		return nil, fmt.Errorf("clamp minimum %v is above maximum %v", *min, *max)
+	}
+	return &Clamp{min, max}, nil
+}
+
+func (cl *Clamp) Apply(v float64, ts time.Time) (float64, error) {
+	if cl.min != nil && v < *cl.min {
+		return *cl.min, nil
+	}
+	if cl.max != nil && v > *cl.max {
+		return *cl.max, nil
+	}
+	return v, nil
+}
+
+// Deadband holds the output steady until a sample differs from it by more
+// than the band, so small fluctuations do not change the value.
+type Deadband struct {
+	band   float64
+	value  float64
+	primed bool
+}
+
+func NewDeadband(band float64) (*Deadband, error) {
+	if band <= 0 {
+		return nil, fmt.Errorf("deadband requires a positive band")
+	}
+	return &Deadband{band: band}, nil
+}
+
+func (db *Deadband) Apply(v float64, ts time.Time) (float64, error) {
+	if !db.primed || math.Abs(v-db.value) > db.band {
+		db.value = v
+		db.primed = true
+	}
+	return db.value, nil
+}
@@ -0,0 +1,146 @@
+package reading
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func fptr(v float64) *float64 {
+	return &v
+}
+
+type filterStep struct {
+	in     float64
+	out    float64
+	reject bool
+}
+
+func runSteps(t *testing.T, stage Stage, interval time.Duration, steps []filterStep) {
+	t.Helper()
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for n, st := range steps {
+		got, err := stage.Apply(st.in, ts)
+		if (err == ErrRejected) != st.reject {
+			t.Errorf("step %d (%v): error %v, expected rejected %v", n, st.in, err, st.reject)
+		}
+		if math.Abs(got-st.out) > 1e-9 {
+			t.Errorf("step %d (%v): got %v, expected %v", n, st.in, got, st.out)
+		}
+		ts = ts.Add(interval)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	tests := []struct {
+		name     string
+		stage    func() (Stage, error)
+		interval time.Duration
+		steps    []filterStep
+	}{
+		{
+			name:  "moving average",
+			stage: func() (Stage, error) { return NewMovingAverage(3) },
+			steps: []filterStep{{3, 3, false}, {6, 4.5, false}, {9, 6, false}, {12, 9, false}, {0, 7, false}},
+		},
+		{
+			name:  "moving average window of 1",
+			stage: func() (Stage, error) { return NewMovingAverage(1) },
+			steps: []filterStep{{3, 3, false}, {6, 6, false}},
+		},
+		{
+			name:  "median odd window",
+			stage: func() (Stage, error) { return NewMedian(3) },
+			steps: []filterStep{{20, 20, false}, {100, 60, false}, {21, 21, false}, {22, 22, false}, {-50, 21, false}},
+		},
+		{
+			name:  "median even window",
+			stage: func() (Stage, error) { return NewMedian(4) },
+			steps: []filterStep{{1, 1, false}, {4, 2.5, false}, {2, 2, false}, {10, 3, false}, {3, 3.5, false}},
+		},
+		{
+			name:  "exponential",
+			stage: func() (Stage, error) { return NewExponential(0.5) },
+			steps: []filterStep{{10, 10, false}, {20, 15, false}, {20, 17.5, false}, {0, 8.75, false}},
+		},
+		{
+			name:  "exponential alpha of 1",
+			stage: func() (Stage, error) { return NewExponential(1) },
+			steps: []filterStep{{10, 10, false}, {20, 20, false}},
+		},
+		{
+			name:     "spike reject",
+			stage:    func() (Stage, error) { return NewSpikeReject(1, 2) },
+			interval: time.Second,
+			steps: []filterStep{
+				{20, 20, false},
+				{20.5, 20.5, false},
+				{85, 20.5, true},
+				{21, 21, false},
+				// a step change is accepted after two rejections
+				{40, 21, true},
+				{40, 21, true},
+				{40, 40, false},
+				{40.5, 40.5, false},
+			},
+		},
+		{
+			name:     "spike rate uses the time between samples",
+			stage:    func() (Stage, error) { return NewSpikeReject(1, 0) },
+			interval: 10 * time.Second,
+			steps:    []filterStep{{20, 20, false}, {29, 29, false}, {45, 29, true}, {38, 38, false}},
+		},
+		{
+			name:  "clamp",
+			stage: func() (Stage, error) { return NewClamp(fptr(0), fptr(100)) },
+			steps: []filterStep{{-5, 0, false}, {50, 50, false}, {150, 100, false}},
+		},
+		{
+			name:  "clamp minimum only",
+			stage: func() (Stage, error) { return NewClamp(fptr(0), nil) },
+			steps: []filterStep{{-5, 0, false}, {1e6, 1e6, false}},
+		},
+		{
+			name:  "deadband",
+			stage: func() (Stage, error) { return NewDeadband(0.5) },
+			steps: []filterStep{
+				{20, 20, false},
+				{20.25, 20, false},
+				{19.5, 20, false},
+				{20.75, 20.75, false},
+				{20.5, 20.75, false},
+				{19, 19, false},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stage, err := tc.stage()
+			if err != nil {
+				t.Fatal(err)
+			}
+			runSteps(t, stage, tc.interval, tc.steps)
+		})
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		stage func() (Stage, error)
+	}{
+		{"moving average window", func() (Stage, error) { return NewMovingAverage(0) }},
+		{"median window", func() (Stage, error) { return NewMedian(0) }},
+		{"exponential alpha of 0", func() (Stage, error) { return NewExponential(0) }},
+		{"exponential alpha above 1", func() (Stage, error) { return NewExponential(1.5) }},
+		{"spike rate", func() (Stage, error) { return NewSpikeReject(0, 3) }},
+		{"clamp limits reversed", func() (Stage, error) { return NewClamp(fptr(10), fptr(0)) }},
+		{"deadband band", func() (Stage, error) { return NewDeadband(0) }},
+	}
+	for _, tc := range tests {
+		if _, err := tc.stage(); err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package reading
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRejected is returned by a stage to drop a sample.
+var ErrRejected = errors.New("sample rejected")
+
+// Stage is a single processing step applied to each new sample of a reading.
+type Stage interface {
+	Apply(v float64, ts time.Time) (float64, error)
+}
+
+// Pipeline holds the stages configured for each named reading of a device,
+// along with the latest processed value. Devices pass every new sample to
+// Process and call Apply on their output. A nil Pipeline does nothing.
+type Pipeline struct {
+	mu     sync.Mutex
+	stages map[string][]Stage
+	values map[string]float64
+}
+
+func NewPipeline() *Pipeline {
+	return &Pipeline{stages: make(map[string][]Stage), values: make(map[string]float64)}
+}
+
+func (p *Pipeline) AddStage(name string, stage Stage) {
+	p.mu.Lock()
+	p.stages[name] = append(p.stages[name], stage)
+	p.mu.Unlock()
+}
+
+func (p *Pipeline) HasStages(name string) bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.stages[name]) > 0
+}
+
+// Process passes a new sample through the stages for the reading. If a stage
+// rejects the sample the previous value is kept.
+func (p *Pipeline) Process(name string, v float64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stages, ck := p.stages[name]
+	if !ck {
+		return
+	}
+	now := time.Now()
+	for _, s := range stages {
+		var err error
+		if v, err = s.Apply(v, now); err != nil {
+			return
+		}
+	}
+	p.values[name] = v
+}
+
+// Apply replaces the raw values in a device's output with the processed
+// values, keeping the raw value as <name>_raw.
+func (p *Pipeline) Apply(rv map[string]interface{}) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for name := range p.stages {
+		raw, ck := rv[name]
+		if !ck {
+			continue
+		}
+		if _, ok := Float(raw); !ok {
+			continue
+		}
+		rv[name+"_raw"] = raw
+		if v, ok := p.values[name]; ok {
+			rv[name] = v
+		}
+	}
+}
+
+// Source returns the current readings for a service, as served over HTTP.
+type Source func() map[string]interface{}
+
+// Float returns the numeric value of a reading, as found in device output.
+func Float(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
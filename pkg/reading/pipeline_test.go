@@ -0,0 +1,73 @@
+package reading
+
+import (
+	"testing"
+)
+
+func TestPipelineOrder(t *testing.T) {
+	// doubling then clamping gives a different answer to clamping then
+	// doubling, so the order the stages are added must be kept
+	tests := []struct {
+		name   string
+		stages []Stage
+		want   float64
+	}{
+		{"gain then clamp", []Stage{NewLinear(2, 0), &Clamp{max: fptr(10)}}, 10},
+		{"clamp then gain", []Stage{&Clamp{max: fptr(10)}, NewLinear(2, 0)}, 12},
+		{"offset then gain", []Stage{NewLinear(1, 1), NewLinear(3, 0)}, 21},
+		{"gain then offset", []Stage{NewLinear(3, 0), NewLinear(1, 1)}, 19},
+	}
+	for _, tc := range tests {
+		p := NewPipeline()
+		for _, s := range tc.stages {
+			p.AddStage("temp", s)
+		}
+		p.Process("temp", 6)
+		rv := map[string]interface{}{"temp": 6.0}
+		p.Apply(rv)
+		if rv["temp"] != tc.want {
+			t.Errorf("%s: got %v, expected %v", tc.name, rv["temp"], tc.want)
+		}
+	}
+}
+
+func TestPipelineProcess(t *testing.T) {
+	p := NewPipeline()
+	ma, _ := NewMovingAverage(2)
+	spike, _ := NewSpikeReject(0.001, 5)
+	p.AddStage("temp", spike)
+	p.AddStage("temp", ma)
+
+	p.Process("temp", 20)
+	// the spike is rejected before reaching the average, which keeps the
+	// last processed value
+	p.Process("temp", 90)
+	rv := map[string]interface{}{"temp": 90.0, "humidity": 55.0, "state": "on"}
+	p.Apply(rv)
+	if rv["temp"] != 20.0 || rv["temp_raw"] != 90.0 {
+		t.Errorf("temp %v raw %v, expected 20 and the raw 90", rv["temp"], rv["temp_raw"])
+	}
+	if _, ck := rv["humidity_raw"]; ck {
+		t.Error("a reading without stages was given a raw value")
+	}
+	if !p.HasStages("temp") || p.HasStages("humidity") {
+		t.Error("HasStages is incorrect")
+	}
+
+	// a reading that is not a number is left alone
+	p.AddStage("state", NewLinear(2, 0))
+	p.Apply(rv)
+	if rv["state"] != "on" {
+		t.Errorf("state %v, expected it unchanged", rv["state"])
+	}
+	if _, ck := rv["state_raw"]; ck {
+		t.Error("a non numeric reading was given a raw value")
+	}
+
+	var np *Pipeline
+	np.Process("temp", 1)
+	np.Apply(rv)
+	if np.HasStages("temp") {
+		t.Error("a nil pipeline has no stages")
+	}
+}
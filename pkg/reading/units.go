@@ -0,0 +1,68 @@
+package reading
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type unitDef struct {
+	quantity string
+	// value in the base unit = value * scale + offset
+	scale  float64
+	offset float64
+}
+
+var units = map[string]unitDef{
+	"°c":   {"temperature", 1, 0},
+	"c":    {"temperature", 1, 0},
+	"degc": {"temperature", 1, 0},
+	"°f":   {"temperature", 5.0 / 9.0, -32 * 5.0 / 9.0},
+	"f":    {"temperature", 5.0 / 9.0, -32 * 5.0 / 9.0},
+	"degf": {"temperature", 5.0 / 9.0, -32 * 5.0 / 9.0},
+	"k":    {"temperature", 1, -273.15},
+
+	"w":  {"power", 1, 0},
+	"kw": {"power", 1000, 0},
+
+	"wh":  {"energy", 1, 0},
+	"kwh": {"energy", 1000, 0},
+
+	"m³/h": {"flow", 1, 0},
+	"m3/h": {"flow", 1, 0},
+	"l/s":  {"flow", 3.6, 0},
+	"l/h":  {"flow", 0.001, 0},
+}
+
+// Conversion converts values between two units of the same quantity.
+type Conversion struct {
+	From  string
+	To    string
+	scale float64
+	shift float64
+}
+
+func NewConversion(from, to string) (*Conversion, error) {
+	f, ck := units[strings.ToLower(from)]
+	if !ck {
+		return nil, fmt.Errorf("unknown units '%s'", from)
+	}
+	t, ck := units[strings.ToLower(to)]
+	if !ck {
+		return nil, fmt.Errorf("unknown units '%s'", to)
+	}
+	if f.quantity != t.quantity {
+		return nil, fmt.Errorf("unable to convert %s (%s) to %s (%s)", from, f.quantity, to, t.quantity)
+	}
+	// base = v * f.scale + f.offset, out = (base - t.offset) / t.scale
+	return &Conversion{
+		From:  from,
+		To:    to,
+		scale: f.scale / t.scale,
+		shift: (f.offset - t.offset) / t.scale,
+	}, nil
+}
+
+func (cv *Conversion) Apply(v float64, ts time.Time) (float64, error) {
+	return v*cv.scale + cv.shift, nil
+}
@@ -0,0 +1,55 @@
+package reading
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestConversion(t *testing.T) {
+	tests := []struct {
+		from, to string
+		in, out  float64
+	}{
+		{"°C", "°F", 100, 212},
+		{"°C", "°F", -40, -40},
+		{"degF", "C", 32, 0},
+		{"F", "K", 212, 373.15},
+		{"K", "°C", 0, -273.15},
+		{"°C", "°C", 21.5, 21.5},
+		{"W", "kW", 1500, 1.5},
+		{"kW", "W", 0.25, 250},
+		{"kWh", "Wh", 1.2, 1200},
+		{"l/s", "m³/h", 10, 36},
+		{"m3/h", "l/h", 1.5, 1500},
+		{"L/H", "l/s", 3600, 1},
+	}
+	for _, tc := range tests {
+		cv, err := NewConversion(tc.from, tc.to)
+		if err != nil {
+			t.Errorf("%s to %s: %s", tc.from, tc.to, err)
+			continue
+		}
+		got, _ := cv.Apply(tc.in, time.Now())
+		if math.Abs(got-tc.out) > 1e-9 {
+			t.Errorf("%v %s gave %v %s, expected %v", tc.in, tc.from, got, tc.to, tc.out)
+		}
+	}
+}
+
+func TestConversionErrors(t *testing.T) {
+	tests := []struct {
+		from, to string
+	}{
+		{"°C", "kW"},
+		{"Wh", "W"},
+		{"m³/h", "°C"},
+		{"bar", "psi"},
+		{"°C", ""},
+	}
+	for _, tc := range tests {
+		if _, err := NewConversion(tc.from, tc.to); err == nil {
+			t.Errorf("%s to %s: expected an error", tc.from, tc.to)
+		}
+	}
+}
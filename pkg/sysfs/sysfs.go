@@ -0,0 +1,241 @@
+package sysfs
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zathras777/sensors/pkg/reading"
+)
+
+type Attribute struct {
+	Name string
+	Path string
+
+	scale      float64
+	offset     float64
+	scaleFile  string
+	offsetFile string
+
+	value      float64
+	valueAvail bool
+	fault      string
+}
+
+type SysfsDevice struct {
+	Name     string
+	Root     string
+	Interval int
+
+	attributes  []*Attribute
+	pipeline    *reading.Pipeline
+	stopChannel chan bool
+}
+
+func NewSysfsDevice(name string, interval int) *SysfsDevice {
+	if interval <= 0 {
+		interval = 30
+	}
+	return &SysfsDevice{
+		Name:        name,
+		Root:        "/sys",
+		Interval:    interval,
+		stopChannel: make(chan bool, 1),
+	}
+}
+
+func (sd *SysfsDevice) SetPipeline(p *reading.Pipeline) {
+	sd.pipeline = p
+}
+
+// AddAttribute adds a sysfs attribute file to be read. Paths may be absolute
+// (/sys/...) or relative to the root. If chip is given, the path is relative
+// to the hwmon directory whose name file matches it. A scale of 0 selects the
+// default for the attribute type, e.g. 0.001 for millidegree temperatures.
+// The value reported is (raw + file offset) * file scale * scale + offset.
+func (sd *SysfsDevice) AddAttribute(name, chip, path string, scale, offset float64) error {
+	if chip != "" {
+		dir, err := sd.FindHwmon(chip)
+		if err != nil {
+			return err
+		}
+		path = filepath.Join(dir, path)
+	} else {
+		path = sd.resolve(path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	attr := Attribute{Name: name, Path: path, scale: scale, offset: offset}
+	if attr.scale == 0 {
+		attr.scale = defaultScale(filepath.Base(path))
+	}
+	if base, ck := strings.CutSuffix(path, "_raw"); ck {
+		attr.scaleFile = sibling(base, "_scale")
+		attr.offsetFile = sibling(base, "_offset")
+	}
+	sd.attributes = append(sd.attributes, &attr)
+	return nil
+}
+
+func (sd *SysfsDevice) resolve(path string) string {
+	if rel, ck := strings.CutPrefix(path, "/sys/"); ck {
+		return filepath.Join(sd.Root, rel)
+	}
+	if !filepath.IsAbs(path) {
+		return filepath.Join(sd.Root, path)
+	}
+	return path
+}
+
+// FindHwmon returns the directory of the hwmon device with the given name.
+func (sd *SysfsDevice) FindHwmon(chip string) (string, error) {
+	matches, _ := filepath.Glob(filepath.Join(sd.Root, "class", "hwmon", "hwmon*", "name"))
+	for _, fn := range matches {
+		dat, err := os.ReadFile(fn)
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(dat)) == chip {
+			return filepath.Dir(fn), nil
+		}
+	}
+	return "", fmt.Errorf("no hwmon device named '%s' found", chip)
+}
+
+// sibling returns the scale or offset file for an IIO raw channel, checking
+// for a per channel file before the shared one, e.g. in_temp0_scale then
+// in_temp_scale.
+func sibling(base, suffix string) string {
+	if _, err := os.Stat(base + suffix); err == nil {
+		return base + suffix
+	}
+	shared := strings.TrimRight(base, "0123456789")
+	if _, err := os.Stat(shared + suffix); err == nil {
+		return shared + suffix
+	}
+	return ""
+}
+
+func defaultScale(fn string) float64 {
+	switch {
+	case fn == "temp":
+		// thermal_zone
+		return 0.001
+	case strings.HasPrefix(fn, "in_temp"), strings.HasPrefix(fn, "in_humidityrelative"),
+		strings.HasPrefix(fn, "in_voltage"), strings.HasPrefix(fn, "in_current"):
+		return 0.001
+	case strings.HasPrefix(fn, "in_"):
+		return 1
+	case strings.HasPrefix(fn, "temp"), strings.HasPrefix(fn, "in"),
+		strings.HasPrefix(fn, "curr"), strings.HasPrefix(fn, "humidity"):
+		return 0.001
+	case strings.HasPrefix(fn, "power"), strings.HasPrefix(fn, "energy"):
+		return 0.000001
+	}
+	return 1
+}
+
+func readFloat(fn string) (float64, error) {
+	dat, err := os.ReadFile(fn)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(dat)), 64)
+}
+
+func (attr *Attribute) read() error {
+	raw, err := readFloat(attr.Path)
+	if err != nil {
+		attr.valueAvail = false
+		attr.fault = err.Error()
+		return err
+	}
+	fileScale, fileOffset := 1.0, 0.0
+	if attr.scaleFile != "" {
+		if fileScale, err = readFloat(attr.scaleFile); err != nil {
+			attr.valueAvail = false
+			attr.fault = err.Error()
+			return err
+		}
+	}
+	if attr.offsetFile != "" {
+		if fileOffset, err = readFloat(attr.offsetFile); err != nil {
+			attr.valueAvail = false
+			attr.fault = err.Error()
+			return err
+		}
+	}
+	attr.value = (raw+fileOffset)*fileScale*attr.scale + attr.offset
+	attr.valueAvail = true
+	attr.fault = ""
+	return nil
+}
+
+// ReadOnce reads every attribute, returning an error if none could be read.
+func (sd *SysfsDevice) ReadOnce() error {
+	readCompleted := 0
+	for _, attr := range sd.attributes {
+		if err := attr.read(); err != nil {
+			log.Printf("unable to read %s for %s: %s", attr.Path, sd.Name, err)
+			continue
+		}
+		sd.pipeline.Process(attr.Name, attr.value)
+		readCompleted++
+	}
+	if readCompleted == 0 {
+		return fmt.Errorf("failed to read any attributes")
+	}
+	return nil
+}
+
+func (sd *SysfsDevice) Start() error {
+	if len(sd.attributes) == 0 {
+		return fmt.Errorf("no attributes configured")
+	}
+	sd.ReadOnce()
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(sd.Interval) * time.Second)
+	sysfsLoop:
+		for {
+			select {
+			case <-ticker.C:
+				sd.ReadOnce()
+			case <-sd.stopChannel:
+				break sysfsLoop
+			}
+		}
+		ticker.Stop()
+	}()
+	return nil
+}
+
+func (sd *SysfsDevice) Stop() {
+	sd.stopChannel <- true
+}
+
+func (sd *SysfsDevice) JsonResponse() map[string]interface{} {
+	rv := make(map[string]interface{})
+	var faults []string
+	for _, attr := range sd.attributes {
+		if attr.valueAvail {
+			rv[attr.Name] = attr.value
+		} else {
+			rv[attr.Name] = "unavailable"
+		}
+		if attr.fault != "" {
+			faults = append(faults, attr.Name+": "+attr.fault)
+		}
+	}
+	sd.pipeline.Apply(rv)
+	if len(faults) > 0 {
+		rv["error"] = strings.Join(faults, ", ")
+	}
+	return rv
+}
@@ -0,0 +1,151 @@
+package sysfs
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		fn := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(content+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func fakeSysfs(t *testing.T) string {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"class/hwmon/hwmon0/name":                       "acpitz",
+		"class/hwmon/hwmon3/name":                       "coretemp",
+		"class/hwmon/hwmon3/temp1_input":                "45500",
+		"class/hwmon/hwmon3/in0_input":                  "1200",
+		"class/hwmon/hwmon3/power1_input":               "2500000",
+		"bus/iio/devices/iio:device0/in_temp_raw":       "1000",
+		"bus/iio/devices/iio:device0/in_temp_scale":     "2.5",
+		"bus/iio/devices/iio:device0/in_temp_offset":    "-200",
+		"bus/iio/devices/iio:device0/in_voltage0_raw":   "100",
+		"bus/iio/devices/iio:device0/in_voltage_scale":  "9",
+		"bus/iio/devices/iio:device0/in_voltage0_scale": "0.5",
+		"bus/iio/devices/iio:device0/in_voltage1_raw":   "100",
+		"bus/iio/devices/iio:device1/in_pressure_input": "101.3",
+		"class/thermal/thermal_zone0/temp":              "42000",
+	})
+	return root
+}
+
+func TestAttributeValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		chip   string
+		path   string
+		scale  float64
+		offset float64
+		want   float64
+	}{
+		{"hwmon temperature", "coretemp", "temp1_input", 0, 0, 45.5},
+		{"hwmon voltage", "coretemp", "in0_input", 0, 0, 1.2},
+		{"hwmon power", "coretemp", "power1_input", 0, 0, 2.5},
+		{"hwmon scale and offset", "coretemp", "temp1_input", 0.01, 1, 456},
+		{"iio raw with scale and offset files", "", "bus/iio/devices/iio:device0/in_temp_raw", 0, 0, 2},
+		{"iio channel scale before shared", "", "bus/iio/devices/iio:device0/in_voltage0_raw", 0, 0, 0.05},
+		{"iio shared scale", "", "bus/iio/devices/iio:device0/in_voltage1_raw", 0, 0, 0.9},
+		{"iio processed", "", "bus/iio/devices/iio:device1/in_pressure_input", 0, 0, 101.3},
+		{"thermal zone", "", "/sys/class/thermal/thermal_zone0/temp", 0, 0, 42},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sd := NewSysfsDevice("test", 0)
+			sd.Root = fakeSysfs(t)
+			if err := sd.AddAttribute("value", tc.chip, tc.path, tc.scale, tc.offset); err != nil {
+				t.Fatal(err)
+			}
+			if err := sd.ReadOnce(); err != nil {
+				t.Fatal(err)
+			}
+			got, ck := sd.JsonResponse()["value"].(float64)
+			if !ck || math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("got %v, expected %v", sd.JsonResponse()["value"], tc.want)
+			}
+		})
+	}
+}
+
+func TestAddAttributeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		chip string
+		path string
+	}{
+		{"unknown chip", "nct6775", "temp1_input"},
+		{"missing attribute", "coretemp", "temp9_input"},
+		{"missing path", "", "class/thermal/thermal_zone9/temp"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sd := NewSysfsDevice("test", 0)
+			sd.Root = fakeSysfs(t)
+			if err := sd.AddAttribute("value", tc.chip, tc.path, 0, 0); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestReadFault(t *testing.T) {
+	sd := NewSysfsDevice("test", 0)
+	sd.Root = fakeSysfs(t)
+	if err := sd.AddAttribute("cpu", "coretemp", "temp1_input", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := sd.AddAttribute("zone", "", "class/thermal/thermal_zone0/temp", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(sd.Root, "class/hwmon/hwmon3/temp1_input"))
+
+	if err := sd.ReadOnce(); err != nil {
+		t.Fatalf("one attribute could be read, but got %s", err)
+	}
+	rv := sd.JsonResponse()
+	if rv["cpu"] != "unavailable" {
+		t.Errorf("cpu: got %v, expected unavailable", rv["cpu"])
+	}
+	if rv["zone"] != 42.0 {
+		t.Errorf("zone: got %v, expected 42", rv["zone"])
+	}
+	if _, ck := rv["error"]; !ck {
+		t.Error("expected an error to be reported")
+	}
+
+	os.Remove(filepath.Join(sd.Root, "class/thermal/thermal_zone0/temp"))
+	if err := sd.ReadOnce(); err == nil {
+		t.Error("expected an error when no attributes can be read")
+	}
+}
+
+func TestStartDefaultInterval(t *testing.T) {
+	sd := NewSysfsDevice("test", 0)
+	if sd.Interval != 30 {
+		t.Errorf("interval %d, expected the default of 30", sd.Interval)
+	}
+	if err := sd.Start(); err == nil {
+		t.Error("expected an error with no attributes")
+	}
+
+	sd.Root = fakeSysfs(t)
+	if err := sd.AddAttribute("zone", "", "class/thermal/thermal_zone0/temp", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := sd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	sd.Stop()
+}
@@ -0,0 +1,253 @@
+package w1
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zathras777/sensors/pkg/reading"
+)
+
+// The DS18B20 reports 85°C until the first conversion after power on, so a
+// reading of exactly this value is treated as an error.
+const powerOnReset = 85000
+
+type Probe struct {
+	ID   string
+	Name string
+
+	Value float64
+
+	valueAvail bool
+	fault      string
+}
+
+type W1Device struct {
+	Name         string
+	DevicesPath  string
+	Interval     int
+	AutoDiscover bool
+
+	mu          sync.Mutex
+	probes      map[string]*Probe
+	pipeline    *reading.Pipeline
+	stopChannel chan bool
+}
+
+func NewW1Device(name string, interval int) *W1Device {
+	return &W1Device{
+		Name:        name,
+		DevicesPath: "/sys/bus/w1/devices",
+		Interval:    interval,
+		probes:      make(map[string]*Probe),
+		stopChannel: make(chan bool, 1),
+	}
+}
+
+func (w *W1Device) SetPipeline(p *reading.Pipeline) {
+	w.pipeline = p
+}
+
+// AddProbe assigns a friendly name to the probe with the given ROM ID, e.g.
+// 28-0316a2795bff. If name is empty the ID is used.
+func (w *W1Device) AddProbe(id, name string) {
+	id = strings.ToLower(id)
+	if name == "" {
+		name = id
+	}
+	w.mu.Lock()
+	w.probes[id] = &Probe{ID: id, Name: name}
+	w.mu.Unlock()
+}
+
+// Discover returns the ROM IDs of all DS18B20 devices present on the bus.
+func (w *W1Device) Discover() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(w.DevicesPath, "28-*"))
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, m := range matches {
+		ids = append(ids, filepath.Base(m))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (w *W1Device) addDiscovered() {
+	ids, err := w.Discover()
+	if err != nil {
+		log.Printf("unable to scan %s for 1-Wire devices: %s", w.DevicesPath, err)
+		return
+	}
+	for _, id := range ids {
+		w.mu.Lock()
+		_, ck := w.probes[id]
+		w.mu.Unlock()
+		if !ck {
+			log.Printf("%s: discovered 1-Wire temperature probe %s", w.Name, id)
+			w.AddProbe(id, id)
+		}
+	}
+}
+
+// parseW1Slave decodes the contents of a w1_slave file, returning the
+// temperature in millidegrees.
+//
+//	72 01 4b 46 7f ff 0e 10 57 : crc=57 YES
+//	72 01 4b 46 7f ff 0e 10 57 t=23125
+func parseW1Slave(data string) (int, error) {
+	lines := strings.Split(strings.TrimSpace(data), "\n")
+	if len(lines) < 2 {
+		return 0, fmt.Errorf("short read from device")
+	}
+	if !strings.HasSuffix(strings.TrimSpace(lines[0]), "YES") {
+		return 0, fmt.Errorf("crc check failed")
+	}
+	scratch, _, _ := strings.Cut(lines[0], ":")
+	raw, err := hex.DecodeString(strings.ReplaceAll(strings.TrimSpace(scratch), " ", ""))
+	if err != nil || len(raw) != 9 {
+		return 0, fmt.Errorf("invalid scratchpad data '%s'", scratch)
+	}
+	if crc8(raw[:8]) != raw[8] {
+		return 0, fmt.Errorf("crc check failed")
+	}
+	_, tval, ck := strings.Cut(lines[1], "t=")
+	if !ck {
+		return 0, fmt.Errorf("no temperature found")
+	}
+	milli, err := strconv.Atoi(strings.TrimSpace(tval))
+	if err != nil {
+		return 0, err
+	}
+	if milli == powerOnReset {
+		return 0, fmt.Errorf("power on reset value returned")
+	}
+	return milli, nil
+}
+
+// crc8 is the Dallas/Maxim 1-Wire CRC.
+func crc8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		for i := 0; i < 8; i++ {
+			mix := (crc ^ b) & 0x01
+			crc >>= 1
+			if mix != 0 {
+				crc ^= 0x8C
+			}
+			b >>= 1
+		}
+	}
+	return crc
+}
+
+// readProbe returns the temperature of the probe in °C.
+func (w *W1Device) readProbe(id string) (float64, error) {
+	dat, err := os.ReadFile(filepath.Join(w.DevicesPath, id, "w1_slave"))
+	if err != nil {
+		return 0, err
+	}
+	milli, err := parseW1Slave(string(dat))
+	if err != nil {
+		return 0, err
+	}
+	return float64(milli) / 1000, nil
+}
+
+// ReadOnce reads every probe. As each read takes the best part of a second
+// the probes are read without holding the lock and the results then stored.
+func (w *W1Device) ReadOnce() error {
+	if w.AutoDiscover {
+		w.addDiscovered()
+	}
+	w.mu.Lock()
+	probes := make([]Probe, 0, len(w.probes))
+	for _, p := range w.probes {
+		probes = append(probes, Probe{ID: p.ID, Name: p.Name})
+	}
+	w.mu.Unlock()
+
+	readCompleted := 0
+	for n := range probes {
+		p := &probes[n]
+		v, err := w.readProbe(p.ID)
+		if err != nil {
+			log.Printf("unable to read 1-Wire probe %s [%s]: %s", p.Name, p.ID, err)
+			p.fault = err.Error()
+			continue
+		}
+		p.Value, p.valueAvail = v, true
+		w.pipeline.Process(p.Name, p.Value)
+		readCompleted++
+	}
+
+	w.mu.Lock()
+	for _, res := range probes {
+		if p, ck := w.probes[res.ID]; ck {
+			p.Value, p.valueAvail, p.fault = res.Value, res.valueAvail, res.fault
+		}
+	}
+	w.mu.Unlock()
+
+	if readCompleted == 0 {
+		return fmt.Errorf("failed to read any probes")
+	}
+	return nil
+}
+
+func (w *W1Device) Start() error {
+	if !w.AutoDiscover && len(w.probes) == 0 {
+		return fmt.Errorf("no probes configured and discovery is disabled")
+	}
+	w.ReadOnce()
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(w.Interval) * time.Second)
+	w1Loop:
+		for {
+			select {
+			case <-ticker.C:
+				w.ReadOnce()
+			case <-w.stopChannel:
+				break w1Loop
+			}
+		}
+		ticker.Stop()
+	}()
+	return nil
+}
+
+func (w *W1Device) Stop() {
+	w.stopChannel <- true
+}
+
+func (w *W1Device) JsonResponse() map[string]interface{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	rv := make(map[string]interface{})
+	var faults []string
+	for _, p := range w.probes {
+		if p.valueAvail {
+			rv[p.Name] = p.Value
+		} else {
+			rv[p.Name] = "unavailable"
+		}
+		if p.fault != "" {
+			faults = append(faults, p.Name+": "+p.fault)
+		}
+	}
+	w.pipeline.Apply(rv)
+	if len(faults) > 0 {
+		sort.Strings(faults)
+		rv["error"] = strings.Join(faults, ", ")
+	}
+	return rv
+}
@@ -0,0 +1,143 @@
+package w1
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseW1Slave(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		milli int
+		ok    bool
+	}{
+		{"good read", "72 01 4b 46 7f ff 0e 10 57 : crc=57 YES\n72 01 4b 46 7f ff 0e 10 57 t=23125\n", 23125, true},
+		{"negative", "5e ff 4b 46 7f ff 02 10 b6 : crc=b6 YES\n5e ff 4b 46 7f ff 02 10 b6 t=-10125\n", -10125, true},
+		{"crc no", "72 01 4b 46 7f ff 0e 10 57 : crc=57 NO\n72 01 4b 46 7f ff 0e 10 57 t=23125\n", 0, false},
+		{"crc mismatch", "72 01 4b 46 7f ff 0e 10 58 : crc=58 YES\n72 01 4b 46 7f ff 0e 10 58 t=23125\n", 0, false},
+		{"truncated", "72 01 4b 46 7f ff 0e 10 57 : crc=57 YES\n", 0, false},
+		{"truncated scratchpad", "72 01 4b 46 7f : crc=57 YES\n72 01 4b 46 7f t=23125\n", 0, false},
+		{"empty", "", 0, false},
+		{"no temperature", "72 01 4b 46 7f ff 0e 10 57 : crc=57 YES\n72 01 4b 46 7f ff 0e 10 57\n", 0, false},
+		{"bad temperature", "72 01 4b 46 7f ff 0e 10 57 : crc=57 YES\n72 01 4b 46 7f ff 0e 10 57 t=2x\n", 0, false},
+		{"power on reset", "50 05 4b 46 7f ff 0c 10 1c : crc=1c YES\n50 05 4b 46 7f ff 0c 10 1c t=85000\n", 0, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			milli, err := parseW1Slave(tc.data)
+			if (err == nil) != tc.ok {
+				t.Fatalf("error %v, expected ok %v", err, tc.ok)
+			}
+			if milli != tc.milli {
+				t.Errorf("got %d, expected %d", milli, tc.milli)
+			}
+		})
+	}
+}
+
+func TestCrc8(t *testing.T) {
+	tests := []struct {
+		data []byte
+		crc  byte
+	}{
+		// DS18B20 scratchpads at 23.125°C and the 85°C power on value
+		{[]byte{0x72, 0x01, 0x4b, 0x46, 0x7f, 0xff, 0x0e, 0x10}, 0x57},
+		{[]byte{0x50, 0x05, 0x4b, 0x46, 0x7f, 0xff, 0x0c, 0x10}, 0x1c},
+		// ROM code example from Maxim application note 27
+		{[]byte{0x02, 0x1c, 0xb8, 0x01, 0x00, 0x00, 0x00}, 0xa2},
+		{[]byte{}, 0x00},
+	}
+	for _, tc := range tests {
+		if got := crc8(tc.data); got != tc.crc {
+			t.Errorf("% X: crc %02X, expected %02X", tc.data, got, tc.crc)
+		}
+		// the crc of the data followed by its crc is always 0
+		if got := crc8(append(tc.data, tc.crc)); got != 0 {
+			t.Errorf("% X with crc: got %02X, expected 0", tc.data, got)
+		}
+	}
+}
+
+func fakeDevices(t *testing.T, probes map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "w1_bus_master1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for id, content := range probes {
+		dir := filepath.Join(root, id)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if content == "" {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, "w1_slave"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+const goodRead = "72 01 4b 46 7f ff 0e 10 57 : crc=57 YES\n72 01 4b 46 7f ff 0e 10 57 t=23125\n"
+
+func TestDiscover(t *testing.T) {
+	w := NewW1Device("test", 10)
+	w.DevicesPath = fakeDevices(t, map[string]string{
+		"28-0316a2795bff": goodRead,
+		"28-000005e2fdc3": goodRead,
+		"10-000802b5f4d1": goodRead,
+	})
+	ids, err := w.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"28-000005e2fdc3", "28-0316a2795bff"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got %v, expected %v", ids, want)
+	}
+}
+
+func TestReadOnce(t *testing.T) {
+	w := NewW1Device("test", 10)
+	w.DevicesPath = fakeDevices(t, map[string]string{
+		"28-0316a2795bff": goodRead,
+		"28-000005e2fdc3": "50 05 4b 46 7f ff 0c 10 1c : crc=1c YES\n50 05 4b 46 7f ff 0c 10 1c t=85000\n",
+		"28-00000a1b2c3d": "",
+	})
+	w.AutoDiscover = true
+	w.AddProbe("28-0316A2795BFF", "")
+	w.AddProbe("28-00000a1b2c3d", "loft")
+
+	if err := w.ReadOnce(); err != nil {
+		t.Fatal(err)
+	}
+	rv := w.JsonResponse()
+	if rv["28-0316a2795bff"] != 23.125 {
+		t.Errorf("got %v, expected 23.125 under the lowercased id", rv["28-0316a2795bff"])
+	}
+	if rv["28-000005e2fdc3"] != "unavailable" {
+		t.Errorf("discovered probe at power on reset: got %v, expected unavailable", rv["28-000005e2fdc3"])
+	}
+	if rv["loft"] != "unavailable" {
+		t.Errorf("missing probe: got %v, expected unavailable", rv["loft"])
+	}
+	if _, ck := rv["error"]; !ck {
+		t.Error("expected the faults to be reported")
+	}
+
+	os.Remove(filepath.Join(w.DevicesPath, "28-0316a2795bff", "w1_slave"))
+	if err := w.ReadOnce(); err == nil {
+		t.Error("expected an error when no probes can be read")
+	}
+}
+
+func TestStartWithoutProbes(t *testing.T) {
+	w := NewW1Device("test", 10)
+	if err := w.Start(); err == nil {
+		t.Error("expected an error with no probes and discovery disabled")
+	}
+}
@@ -0,0 +1,81 @@
+package zcan
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// PropertyResult is the response to a property query. Value, Min, Max and
+// Step are only set when they were requested and a type was given to decode
+// them.
+type PropertyResult struct {
+	Node     byte   `json:"node"`
+	Unit     byte   `json:"unit"`
+	SubUnit  byte   `json:"subunit"`
+	Property byte   `json:"property"`
+	Raw      string `json:"raw"`
+	Value    any    `json:"value,omitempty"`
+	Min      any    `json:"min,omitempty"`
+	Max      any    `json:"max,omitempty"`
+	Step     any    `json:"step,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// QueryProperty requests a property, with the flags selecting the actual
+// value, range and/or step size. If typ is not nil the values returned are
+// decoded, in the order value, min, max and step.
+func (dev *ZehnderDevice) QueryProperty(dest ZehnderDestination, prop byte, flags ZehnderTypeFlag, typ *ZehnderType) (*PropertyResult, error) {
+	if flags == ZehnderRMITypeNoValue {
+		flags = ZehnderRMITypeActualValue
+	}
+	res := PropertyResult{Node: dest.DestNodeId, Unit: dest.Unit, SubUnit: dest.SubUnit, Property: prop}
+	rmi, err := dest.Get(dev, prop, flags)
+	if rmi != nil {
+		res.Raw = strings.ToUpper(hex.EncodeToString(rmi.Data[:rmi.DataLength]))
+	}
+	if err != nil {
+		res.Error = err.Error()
+		return &res, err
+	}
+	if typ == nil {
+		return &res, nil
+	}
+
+	fields := []struct {
+		flag ZehnderTypeFlag
+		dest *any
+	}{
+		{ZehnderRMITypeActualValue, &res.Value},
+		{ZehnderRMITypeRange, &res.Min},
+		{ZehnderRMITypeRange, &res.Max},
+		{ZehnderRMITypeStepSize, &res.Step},
+	}
+	for _, fld := range fields {
+		if flags&fld.flag == 0 {
+			continue
+		}
+		if *fld.dest, err = rmi.GetData(*typ); err != nil {
+			res.Error = err.Error()
+			break
+		}
+	}
+	return &res, nil
+}
+
+// WalkProperties queries each property in the range and returns those that
+// the node responded to without an error. The walk stops if the node does
+// not respond at all.
+func (dev *ZehnderDevice) WalkProperties(dest ZehnderDestination, from, to byte, flags ZehnderTypeFlag, typ *ZehnderType) ([]PropertyResult, error) {
+	var results []PropertyResult
+	for prop := int(from); prop <= int(to); prop++ {
+		res, err := dev.QueryProperty(dest, byte(prop), flags, typ)
+		var rmiErr RMIError
+		if err == nil {
+			results = append(results, *res)
+		} else if !errors.As(err, &rmiErr) {
+			return results, err
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,182 @@
+package zcan
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.einride.tech/can"
+)
+
+// DumpFrame is a frame read from a capture, with the time it was captured if
+// the capture recorded it.
+type DumpFrame struct {
+	Time      time.Time
+	Interface string
+	Frame     can.Frame
+}
+
+// FormatCandump returns the frame in the candump -L log format, e.g.
+// "(1697041234.123456) can0 1F015057#0102".
+func FormatCandump(t time.Time, iface string, frame can.Frame) string {
+	return fmt.Sprintf("(%d.%06d) %s %s", t.Unix(), t.Nanosecond()/1000, iface, frame)
+}
+
+// ParseCandump parses a line captured by candump, either in the -L log format
+// or the default format, with or without an absolute timestamp, e.g.
+//
+//	(1697041234.123456) can0 1F015057#0102
+//	(1697041234.123456)  can0  1F015057   [2]  01 02
+//	  can0  1F015057   [2]  01 02
+//
+// Lines with just a frame, as written by earlier versions of CaptureAll, are
+// also accepted.
+func ParseCandump(line string) (DumpFrame, error) {
+	var df DumpFrame
+	fields := strings.Fields(line)
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "(") && strings.HasSuffix(fields[0], ")") {
+		ts, err := parseCandumpTime(strings.Trim(fields[0], "()"))
+		if err != nil {
+			return df, fmt.Errorf("invalid timestamp: %s", line)
+		}
+		df.Time = ts
+		fields = fields[1:]
+	}
+
+	switch {
+	case len(fields) == 1:
+		// just a frame
+	case len(fields) >= 2 && strings.Contains(fields[1], "#"):
+		// log format, ignoring any direction flag following the frame
+		df.Interface = fields[0]
+		fields = fields[1:2]
+	case len(fields) >= 3 && strings.HasPrefix(fields[2], "["):
+		df.Interface = fields[0]
+		frame, err := parseCandumpDefault(fields[1:])
+		if err != nil {
+			return df, fmt.Errorf("%s: %s", err, line)
+		}
+		df.Frame = frame
+		return df, nil
+	default:
+		return df, fmt.Errorf("unrecognised candump line: %s", line)
+	}
+	if strings.Contains(fields[0], "##") {
+		return df, fmt.Errorf("CAN FD frames are not supported: %s", line)
+	}
+	if err := df.Frame.UnmarshalString(strings.ToUpper(fields[0])); err != nil {
+		return df, err
+	}
+	return df, nil
+}
+
+func parseCandumpTime(s string) (time.Time, error) {
+	secs, frac, _ := strings.Cut(s, ".")
+	sec, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var nsec int64
+	if frac != "" {
+		frac = (frac + "000000000")[:9]
+		if nsec, err = strconv.ParseInt(frac, 10, 64); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.Unix(sec, nsec), nil
+}
+
+// parseCandumpDefault parses the id, length and data of the default candump
+// format, i.e. "1F015057 [2] 01 02".
+func parseCandumpDefault(fields []string) (can.Frame, error) {
+	var frame can.Frame
+	id, err := strconv.ParseUint(fields[0], 16, 32)
+	if err != nil {
+		return frame, fmt.Errorf("invalid frame ID")
+	}
+	frame.ID = uint32(id)
+	frame.IsExtended = len(fields[0]) == 8
+	n, err := strconv.Atoi(strings.Trim(fields[1], "[]"))
+	if err != nil || n > 8 {
+		return frame, fmt.Errorf("invalid length")
+	}
+	frame.Length = uint8(n)
+	if len(fields) > 2 && fields[2] == "remote" {
+		frame.IsRemote = true
+		return frame, nil
+	}
+	if len(fields)-2 < n {
+		return frame, fmt.Errorf("missing data")
+	}
+	data, err := hex.DecodeString(strings.Join(fields[2:2+n], ""))
+	if err != nil {
+		return frame, fmt.Errorf("invalid data")
+	}
+	copy(frame.Data[:], data)
+	return frame, nil
+}
+
+// ReadCandump reads all the frames from a capture, skipping empty lines and
+// comments. Lines that cannot be parsed are logged and skipped.
+func ReadCandump(r io.Reader) ([]DumpFrame, error) {
+	var frames []DumpFrame
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		df, err := ParseCandump(line)
+		if err != nil {
+			log.Printf("line %d: %s", lineNo, err)
+			continue
+		}
+		frames = append(frames, df)
+	}
+	return frames, scanner.Err()
+}
+
+// ReplayDumpFile passes the frames from a capture through the device as if
+// they had been received. If the capture has timestamps, the frames are
+// replayed with the original timing sped up by the speed given, so 2 replays
+// twice as fast. With a speed of 0 the frames are replayed as fast as they
+// can be processed. It returns once every frame has been decoded, and should
+// not be used while the device is also receiving from a CAN interface.
+func (dev *ZehnderDevice) ReplayDumpFile(filename string, speed float64) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	frames, err := ReadCandump(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if len(frames) == 0 {
+		return fmt.Errorf("no frames found in %s", filename)
+	}
+
+	start := time.Now()
+	first := frames[0].Time
+	for _, df := range frames {
+		if speed > 0 && !df.Time.IsZero() && !first.IsZero() {
+			due := start.Add(time.Duration(float64(df.Time.Sub(first)) / speed))
+			if wait := time.Until(due); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+		dev.queueFrame(df.Frame)
+	}
+	// Wait for the last frames to be decoded before returning, so the
+	// results include them.
+	dev.frames.Wait()
+	return nil
+}
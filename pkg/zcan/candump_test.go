@@ -0,0 +1,216 @@
+package zcan
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.einride.tech/can"
+)
+
+func TestReplayDecodesEveryFrame(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var lines []string
+	for n := 0; n < 200; n++ {
+		frame := can.Frame{ID: 274<<14 | 1, IsExtended: true, Length: 2, Data: can.Data{byte(n), 0}}
+		lines = append(lines, FormatCandump(start.Add(time.Duration(n)*time.Millisecond), "can0", frame))
+	}
+	// the last frames are a heartbeat and a frame that is not decoded
+	lines = append(lines, FormatCandump(start, "can0", can.Frame{ID: 0x10000001, IsExtended: true}))
+	lines = append(lines, FormatCandump(start, "can0", can.Frame{ID: 0x05000001, IsExtended: true}))
+	fn := filepath.Join(t.TempDir(), "capture.log")
+	if err := os.WriteFile(fn, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dev := NewZehnderDevice(0)
+	if err := dev.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer dev.Stop()
+	if err := dev.ReplayDumpFile(fn, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ck := dev.PDOValue("extract_air_temperature"); !ck || v != 19.9 {
+		t.Errorf("extract_air_temperature %v, expected the last value of 19.9", v)
+	}
+	dev.mu.Lock()
+	_, seen := dev.nodes[1]
+	dev.mu.Unlock()
+	if !seen {
+		t.Error("the heartbeat from node 1 was not decoded")
+	}
+}
+
+func TestParseCandump(t *testing.T) {
+	ts := time.Unix(1697041234, 123456000)
+	tests := []struct {
+		name  string
+		line  string
+		time  time.Time
+		iface string
+		frame can.Frame
+	}{
+		{"log format", "(1697041234.123456) can0 1F015057#0102", ts, "can0",
+			can.Frame{ID: 0x1F015057, IsExtended: true, Length: 2, Data: can.Data{1, 2}}},
+		{"log format with direction", "(1697041234.123456) can0 1F015057#0102 R", ts, "can0",
+			can.Frame{ID: 0x1F015057, IsExtended: true, Length: 2, Data: can.Data{1, 2}}},
+		{"log format lower case", "(1697041234.123456) can0 1f015057#0a0b", ts, "can0",
+			can.Frame{ID: 0x1F015057, IsExtended: true, Length: 2, Data: can.Data{0x0a, 0x0b}}},
+		{"log format remote", "(1697041234.123456) can0 1F015057#R", ts, "can0",
+			can.Frame{ID: 0x1F015057, IsExtended: true, IsRemote: true}},
+		{"log format remote with length", "(1697041234.123456) vcan1 123#R4", ts, "vcan1",
+			can.Frame{ID: 0x123, IsRemote: true, Length: 4}},
+		{"default", "  can0  1F015057   [2]  01 02", time.Time{}, "can0",
+			can.Frame{ID: 0x1F015057, IsExtended: true, Length: 2, Data: can.Data{1, 2}}},
+		{"default standard id", "  can0  123   [8]  11 22 33 44 55 66 77 88", time.Time{}, "can0",
+			can.Frame{ID: 0x123, Length: 8, Data: can.Data{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88}}},
+		{"default no data", "  can0  10000001   [0]", time.Time{}, "can0",
+			can.Frame{ID: 0x10000001, IsExtended: true}},
+		{"default with timestamp", "(1697041234.123456)  can0  1F015057   [2]  01 02", ts, "can0",
+			can.Frame{ID: 0x1F015057, IsExtended: true, Length: 2, Data: can.Data{1, 2}}},
+		{"default with ascii", "  can0  1F015057   [2]  41 42   'AB'", time.Time{}, "can0",
+			can.Frame{ID: 0x1F015057, IsExtended: true, Length: 2, Data: can.Data{0x41, 0x42}}},
+		{"default remote", "  can0  1F015057   [3]  remote request", time.Time{}, "can0",
+			can.Frame{ID: 0x1F015057, IsExtended: true, IsRemote: true, Length: 3}},
+		{"frame only", "1F015057#0102", time.Time{}, "",
+			can.Frame{ID: 0x1F015057, IsExtended: true, Length: 2, Data: can.Data{1, 2}}},
+		{"short timestamp", "(1697041234.5) can0 123#", time.Unix(1697041234, 500000000), "can0",
+			can.Frame{ID: 0x123}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			df, err := ParseCandump(tc.line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !df.Time.Equal(tc.time) {
+				t.Errorf("time %v, expected %v", df.Time, tc.time)
+			}
+			if df.Interface != tc.iface {
+				t.Errorf("interface '%s', expected '%s'", df.Interface, tc.iface)
+			}
+			if df.Frame != tc.frame {
+				t.Errorf("frame %v, expected %v", df.Frame, tc.frame)
+			}
+		})
+	}
+}
+
+func TestParseCandumpMalformed(t *testing.T) {
+	lines := []string{
+		"",
+		"(1697041234.123456)",
+		"(16970x1234.123456) can0 1F015057#0102",
+		"(1697041234.12x) can0 1F015057#0102",
+		"can0 1F015057 2 01 02",
+		"can0 1F015057#01020",
+		"can0 1F015057#0G",
+		"can0 1F015057#010203040506070809",
+		"can0 1F015057##10102",
+		"can0 XYZ [1] 00",
+		"can0 1F015057 [9] 01 02 03 04 05 06 07 08 09",
+		"can0 1F015057 [x] 01",
+		"can0 1F015057 [2] 01",
+		"can0 1F015057 [2] 01 0G",
+	}
+	for _, line := range lines {
+		if df, err := ParseCandump(line); err == nil {
+			t.Errorf("'%s': parsed as %v, expected an error", line, df.Frame)
+		}
+	}
+}
+
+func TestReadCandump(t *testing.T) {
+	capture := `# captured with candump -L
+(1697041234.000000) can0 1F015057#01
+
+not a frame
+(1697041234.100000) can0 1F015057#R
+  can0  1F015057   [1]  03
+(1697041234.2x) can0 1F015057#04
+`
+	frames, err := ReadCandump(strings.NewReader(capture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 3 {
+		t.Fatalf("read %d frames, expected 3", len(frames))
+	}
+	if frames[0].Frame.Data[0] != 1 || !frames[1].Frame.IsRemote || frames[2].Frame.Data[0] != 3 {
+		t.Errorf("read %v", frames)
+	}
+	if frames[1].Time.Sub(frames[0].Time) != 100*time.Millisecond || !frames[2].Time.IsZero() {
+		t.Errorf("times %v, %v and %v", frames[0].Time, frames[1].Time, frames[2].Time)
+	}
+
+	// frames written by FormatCandump are read back unchanged
+	frame := can.Frame{ID: 0x1F015057, IsExtended: true, Length: 3, Data: can.Data{0xde, 0xad, 0x01}}
+	ts := time.Unix(1697041234, 987654000)
+	df, err := ParseCandump(FormatCandump(ts, "can1", frame))
+	if err != nil || df.Frame != frame || !df.Time.Equal(ts) || df.Interface != "can1" {
+		t.Errorf("round trip gave %v %v %s, %v", df.Frame, df.Time, df.Interface, err)
+	}
+}
+
+func TestReplayTiming(t *testing.T) {
+	// three frames captured 200ms apart long ago, as only the times relative
+	// to the first frame are used
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	frame := can.Frame{ID: 274<<14 | 1, IsExtended: true, Length: 2, Data: can.Data{200, 0}}
+	var lines []string
+	for n := 0; n < 3; n++ {
+		lines = append(lines, FormatCandump(start.Add(time.Duration(n)*200*time.Millisecond), "can0", frame))
+	}
+	timed := filepath.Join(t.TempDir(), "timed.log")
+	if err := os.WriteFile(timed, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	untimed := filepath.Join(t.TempDir(), "untimed.log")
+	if err := os.WriteFile(untimed, []byte("can0 00448001#C800\ncan0 00448001#C800\ncan0 00448001#C800\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		speed    float64
+		min, max time.Duration
+	}{
+		{"original timing", timed, 1, 400 * time.Millisecond, 2 * time.Second},
+		{"twice as fast", timed, 2, 200 * time.Millisecond, 390 * time.Millisecond},
+		{"as fast as possible", timed, 0, 0, 150 * time.Millisecond},
+		{"no timestamps", untimed, 1, 0, 150 * time.Millisecond},
+	}
+
+	dev := NewZehnderDevice(0)
+	if err := dev.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer dev.Stop()
+	for _, tc := range tests {
+		began := time.Now()
+		if err := dev.ReplayDumpFile(tc.file, tc.speed); err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if took := time.Since(began); took < tc.min || took > tc.max {
+			t.Errorf("%s: took %s, expected between %s and %s", tc.name, took, tc.min, tc.max)
+		}
+		if v, ck := dev.PDOValue("extract_air_temperature"); !ck || v != 20.0 {
+			t.Errorf("%s: extract_air_temperature %v, expected 20", tc.name, v)
+		}
+	}
+
+	if err := dev.ReplayDumpFile(filepath.Join(t.TempDir(), "missing.log"), 1); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	empty := filepath.Join(t.TempDir(), "empty.log")
+	os.WriteFile(empty, []byte("# nothing\n"), 0644)
+	if err := dev.ReplayDumpFile(empty, 1); err == nil {
+		t.Error("expected an error for a capture without frames")
+	}
+}
@@ -0,0 +1,138 @@
+package zcan
+
+import (
+	_ "embed"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+//go:embed pdo_catalogue.yaml
+var defaultCatalogue []byte
+
+var zehnderTypeNames = map[string]ZehnderType{
+	"bool":    CN_BOOL,
+	"uint8":   CN_UINT8,
+	"uint16":  CN_UINT16,
+	"uint32":  CN_UINT32,
+	"int8":    CN_INT8,
+	"int16":   CN_INT16,
+	"int32":   CN_INT32,
+	"int64":   CN_INT64,
+	"string":  CN_STRING,
+	"time":    CN_TIME,
+	"version": CN_VERSION,
+}
+
+// ParseZehnderType returns the data type with the name, e.g. uint16.
+func ParseZehnderType(name string) (ZehnderType, error) {
+	typ, ck := zehnderTypeNames[strings.ToLower(name)]
+	if !ck {
+		return 0, fmt.Errorf("unknown data type '%s'", name)
+	}
+	return typ, nil
+}
+
+type catalogueSensor struct {
+	Id       int
+	Name     string
+	Slug     string
+	Units    string
+	Type     string
+	Decimals int
+}
+
+type pdoCatalogue struct {
+	Version    string
+	Sensors    []catalogueSensor
+	Conditions []statusRule
+}
+
+// CatalogueVersion is the version of the last PDO catalogue loaded.
+var CatalogueVersion string
+
+func init() {
+	if err := loadCatalogue(defaultCatalogue); err != nil {
+		log.Fatalf("unable to load the default PDO catalogue: %s", err)
+	}
+}
+
+// LoadPDOCatalogue loads PDO definitions from a yaml file. Entries replace
+// any existing definition for the same PDO, so the file only needs to contain
+// new or corrected entries.
+func LoadPDOCatalogue(fn string) error {
+	dat, err := os.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+	if err = loadCatalogue(dat); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+	log.Printf("loaded PDO catalogue %s, version %s", fn, CatalogueVersion)
+	return nil
+}
+
+func loadCatalogue(dat []byte) error {
+	var cat pdoCatalogue
+	if err := yaml.Unmarshal(dat, &cat); err != nil {
+		return err
+	}
+
+	sensors := make(map[int]PDOSensor)
+	slugs := make(map[string]int)
+	for _, cs := range cat.Sensors {
+		if cs.Id <= 0 || cs.Id > 0x7ff {
+			return fmt.Errorf("invalid PDO id %d", cs.Id)
+		}
+		typ, ck := zehnderTypeNames[strings.ToLower(cs.Type)]
+		if !ck {
+			return fmt.Errorf("PDO %d: unknown type '%s'", cs.Id, cs.Type)
+		}
+		sensor := PDOSensor{
+			Name:          cs.Name,
+			slug:          strings.ToLower(cs.Slug),
+			Units:         cs.Units,
+			DataType:      typ,
+			DecimalPlaces: cs.Decimals,
+		}
+		if sensor.Name == "" {
+			sensor.Name = fmt.Sprintf("PDO %d", cs.Id)
+		}
+		if sensor.slug == "" {
+			sensor.slug = strings.ReplaceAll(strings.ToLower(sensor.Name), " ", "_")
+		}
+		if sensor.Units == "" {
+			sensor.Units = UNIT_UNKNOWN
+		}
+		if other, ck := slugs[sensor.slug]; ck && other != cs.Id {
+			return fmt.Errorf("PDO %d: slug '%s' is already used by PDO %d", cs.Id, sensor.slug, other)
+		}
+		slugs[sensor.slug] = cs.Id
+		sensors[cs.Id] = sensor
+	}
+
+	sensorMu.Lock()
+	defer sensorMu.Unlock()
+	for id, sensor := range sensors {
+		for other, poss := range sensorData {
+			if other != id && poss.slug == sensor.slug {
+				if _, ck := sensors[other]; !ck {
+					return fmt.Errorf("PDO %d: slug '%s' is already used by PDO %d", id, sensor.slug, other)
+				}
+			}
+		}
+	}
+	for id, sensor := range sensors {
+		sensorData[id] = sensor
+	}
+	for _, sr := range cat.Conditions {
+		if err := addStatusRule(sr); err != nil {
+			return err
+		}
+	}
+	CatalogueVersion = cat.Version
+	return nil
+}
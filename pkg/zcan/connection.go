@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"sync"
 
 	"go.einride.tech/can/pkg/candevice"
 	"go.einride.tech/can/pkg/socketcan"
@@ -13,9 +14,14 @@ import (
 type zConnection struct {
 	interfaceName string
 	device        *candevice.Device
-	counter       int
 	prevState     bool
-	conn          net.Conn
+
+	// mu guards the socket and its use count. The socket is opened by the
+	// receiver and transmitter routines while others check whether it is
+	// available.
+	mu      sync.Mutex
+	counter int
+	conn    net.Conn
 }
 
 func (conn *zConnection) open_device(interfaceName string) error {
@@ -61,6 +67,8 @@ func (conn *zConnection) open() error {
 	if conn.device == nil {
 		return fmt.Errorf("require an interface name. Have you called Connect()")
 	}
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
 	if conn.counter == 0 {
 		var err error
 		conn.conn, err = socketcan.DialContext(context.Background(), "can", conn.interfaceName)
@@ -76,6 +84,8 @@ func (conn *zConnection) open() error {
 }
 
 func (conn *zConnection) close() {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
 	if conn.counter == 0 {
 		return
 	}
@@ -86,10 +96,21 @@ func (conn *zConnection) close() {
 	}
 }
 
-func (conn zConnection) getReceiver() *socketcan.Receiver {
+// isOpen returns true once the socket has been opened.
+func (conn *zConnection) isOpen() bool {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return conn.conn != nil
+}
+
+func (conn *zConnection) getReceiver() *socketcan.Receiver {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
 	return socketcan.NewReceiver(conn.conn)
 }
 
-func (conn zConnection) getTransmitter() *socketcan.Transmitter {
+func (conn *zConnection) getTransmitter() *socketcan.Transmitter {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
 	return socketcan.NewTransmitter(conn.conn)
 }
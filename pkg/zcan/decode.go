@@ -0,0 +1,93 @@
+package zcan
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Size returns the number of bytes used by a value of the type, or 0 for a
+// string which is terminated by a null byte.
+func (typ ZehnderType) Size() int {
+	switch typ {
+	case CN_BOOL, CN_UINT8, CN_INT8:
+		return 1
+	case CN_UINT16, CN_INT16:
+		return 2
+	case CN_UINT32, CN_INT32, CN_TIME, CN_VERSION:
+		return 4
+	case CN_INT64:
+		return 8
+	}
+	return 0
+}
+
+// zehnderTime converts a CN_TIME value, the number of seconds since the
+// start of 2000 in the local time of the unit.
+func zehnderTime(secs uint32) time.Time {
+	return time.Date(2000, time.January, 1, 0, 0, int(secs), 0, time.Local)
+}
+
+// decodeValue decodes a value of the type from the start of data, returning
+// the value and the number of bytes used. All values are little endian.
+// Unsigned types are returned as uint, signed types as int, CN_TIME as a
+// time.Time and CN_VERSION as a "major.minor" string.
+func decodeValue(typ ZehnderType, data []byte) (any, int, error) {
+	if typ == CN_STRING {
+		end := bytes.IndexByte(data, 0)
+		if end == -1 {
+			return string(data), len(data), nil
+		}
+		return string(data[:end]), end + 1, nil
+	}
+
+	size := typ.Size()
+	if size == 0 {
+		return nil, 0, fmt.Errorf("unknown data type %d", typ)
+	}
+	if len(data) < size {
+		return nil, 0, fmt.Errorf("%d bytes of data is too short for a %d byte value", len(data), size)
+	}
+
+	switch typ {
+	case CN_BOOL:
+		return data[0] == 1, size, nil
+	case CN_UINT8:
+		return uint(data[0]), size, nil
+	case CN_UINT16:
+		return uint(binary.LittleEndian.Uint16(data)), size, nil
+	case CN_UINT32:
+		return uint(binary.LittleEndian.Uint32(data)), size, nil
+	case CN_INT8:
+		return int(int8(data[0])), size, nil
+	case CN_INT16:
+		return int(int16(binary.LittleEndian.Uint16(data))), size, nil
+	case CN_INT32:
+		return int(int32(binary.LittleEndian.Uint32(data))), size, nil
+	case CN_INT64:
+		return int(int64(binary.LittleEndian.Uint64(data))), size, nil
+	case CN_TIME:
+		return zehnderTime(binary.LittleEndian.Uint32(data)), size, nil
+	case CN_VERSION:
+		vers := ZehnderVersionDecode(binary.LittleEndian.Uint32(data))
+		return fmt.Sprintf("%d.%d", vers[0], vers[1]), size, nil
+	}
+	return nil, 0, fmt.Errorf("unknown data type %d", typ)
+}
+
+// scaleValue divides numeric values by 10^decimals, returning a float64.
+// Other values, or any value when decimals is 0, are returned unchanged.
+func scaleValue(v any, decimals int) any {
+	if decimals <= 0 {
+		return v
+	}
+	switch n := v.(type) {
+	case uint:
+		return float64(n) / math.Pow10(decimals)
+	case int:
+		return float64(n) / math.Pow10(decimals)
+	}
+	return v
+}
@@ -0,0 +1,147 @@
+package zcan
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeValue(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  ZehnderType
+		data []byte
+		want any
+		used int
+	}{
+		{"bool true", CN_BOOL, []byte{0x01}, true, 1},
+		{"bool false", CN_BOOL, []byte{0x00}, false, 1},
+		{"uint8", CN_UINT8, []byte{0xFF}, uint(255), 1},
+		{"uint16", CN_UINT16, []byte{0x34, 0x12}, uint(0x1234), 2},
+		{"uint16 maximum", CN_UINT16, []byte{0xFF, 0xFF}, uint(65535), 2},
+		{"uint32", CN_UINT32, []byte{0x78, 0x56, 0x34, 0x12}, uint(0x12345678), 4},
+		{"uint32 maximum", CN_UINT32, []byte{0xFF, 0xFF, 0xFF, 0xFF}, uint(4294967295), 4},
+		{"int8 positive", CN_INT8, []byte{0x7F}, 127, 1},
+		{"int8 negative", CN_INT8, []byte{0xFE}, -2, 1},
+		{"int8 minimum", CN_INT8, []byte{0x80}, -128, 1},
+		{"int16 positive", CN_INT16, []byte{0x34, 0x12}, 0x1234, 2},
+		{"int16 negative", CN_INT16, []byte{0x9C, 0xFF}, -100, 2},
+		{"int16 minimum", CN_INT16, []byte{0x00, 0x80}, -32768, 2},
+		{"int32 negative", CN_INT32, []byte{0xFE, 0xFF, 0xFF, 0xFF}, -2, 4},
+		{"int32 minimum", CN_INT32, []byte{0x00, 0x00, 0x00, 0x80}, -2147483648, 4},
+		{"int64 positive", CN_INT64, []byte{0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00}, 1<<32 + 1, 8},
+		{"int64 negative", CN_INT64, []byte{0xFE, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, -2, 8},
+		{"extra data ignored", CN_UINT16, []byte{0x01, 0x00, 0xFF}, uint(1), 2},
+		{"version", CN_VERSION, []byte{0x00, 0x00, 0x50, 0x40}, "1.5", 4},
+		{"version 2.34", CN_VERSION, []byte{0x00, 0x00, 0x20, 0x82}, "2.34", 4},
+		{"string", CN_STRING, []byte("ComfoAir Q\x00"), "ComfoAir Q", 11},
+		{"string followed by data", CN_STRING, []byte("Q350\x00\x01\x02"), "Q350", 5},
+		{"string without terminator", CN_STRING, []byte("Q350"), "Q350", 4},
+		{"empty string", CN_STRING, []byte{0x00, 'x'}, "", 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, used, err := decodeValue(tc.typ, tc.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v (%T), expected %v (%T)", got, got, tc.want, tc.want)
+			}
+			if used != tc.used {
+				t.Errorf("used %d bytes, expected %d", used, tc.used)
+			}
+		})
+	}
+}
+
+func TestDecodeTime(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want time.Time
+	}{
+		{[]byte{0x00, 0x00, 0x00, 0x00}, time.Date(2000, 1, 1, 0, 0, 0, 0, time.Local)},
+		// 1 day, 1 hour, 1 minute and 1 second
+		{[]byte{0xCD, 0x5F, 0x01, 0x00}, time.Date(2000, 1, 2, 1, 1, 1, 0, time.Local)},
+		// 2024-01-01 is 8766 days after 2000-01-01
+		{[]byte{0x00, 0xBD, 0x24, 0x2D}, time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)},
+	}
+	for _, tc := range tests {
+		got, used, err := decodeValue(CN_TIME, tc.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tm, ok := got.(time.Time); !ok || !tm.Equal(tc.want) || used != 4 {
+			t.Errorf("% X: got %v using %d bytes, expected %v", tc.data, got, used, tc.want)
+		}
+	}
+}
+
+func TestDecodeShortData(t *testing.T) {
+	tests := []struct {
+		typ  ZehnderType
+		data []byte
+	}{
+		{CN_BOOL, nil},
+		{CN_UINT8, []byte{}},
+		{CN_UINT16, []byte{0x01}},
+		{CN_INT16, []byte{0x01}},
+		{CN_UINT32, []byte{0x01, 0x02, 0x03}},
+		{CN_INT32, []byte{0x01, 0x02, 0x03}},
+		{CN_INT64, []byte{0x01, 0x02, 0x03, 0x04}},
+		{CN_TIME, []byte{0x01, 0x02}},
+		{CN_VERSION, []byte{0x01}},
+		{ZehnderType(99), []byte{0x01, 0x02}},
+	}
+	for _, tc := range tests {
+		if v, _, err := decodeValue(tc.typ, tc.data); err == nil {
+			t.Errorf("type %d with % X: expected an error, got %v", tc.typ, tc.data, v)
+		}
+	}
+}
+
+func TestScaleValue(t *testing.T) {
+	tests := []struct {
+		v        any
+		decimals int
+		want     any
+	}{
+		{uint(235), 0, uint(235)},
+		{uint(235), 1, 23.5},
+		{uint(2350), 2, 23.5},
+		{-55, 1, -5.5},
+		{-5, 0, -5},
+		{true, 1, true},
+		{"1.5", 1, "1.5"},
+	}
+	for _, tc := range tests {
+		if got := scaleValue(tc.v, tc.decimals); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("scaleValue(%v, %d) = %v (%T), expected %v (%T)", tc.v, tc.decimals, got, got, tc.want, tc.want)
+		}
+	}
+}
+
+func TestPDOValueGetData(t *testing.T) {
+	tests := []struct {
+		name   string
+		sensor PDOSensor
+		data   []byte
+		want   any
+	}{
+		{"temperature", PDOSensor{DataType: CN_INT16, DecimalPlaces: 1}, []byte{0xEB, 0x00}, 23.5},
+		{"negative temperature", PDOSensor{DataType: CN_INT16, DecimalPlaces: 1}, []byte{0xC9, 0xFF}, -5.5},
+		{"power", PDOSensor{DataType: CN_UINT16}, []byte{0x2A, 0x00}, uint(42)},
+		{"humidity", PDOSensor{DataType: CN_UINT8}, []byte{0x37}, uint(55)},
+		{"version", PDOSensor{DataType: CN_VERSION}, []byte{0x00, 0x00, 0x50, 0x40}, []int{1, 5}},
+		{"short", PDOSensor{DataType: CN_UINT16}, []byte{0x01}, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := PDOValue{Sensor: tc.sensor, Value: tc.data}.GetData()
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v (%T), expected %v (%T)", got, got, tc.want, tc.want)
+			}
+		})
+	}
+}
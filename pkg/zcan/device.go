@@ -1,13 +1,15 @@
 package zcan
 
 import (
-	"bufio"
 	"fmt"
 	"log"
 	"os"
 	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/zathras777/sensors/pkg/reading"
 	"go.einride.tech/can"
 )
 
@@ -19,7 +21,6 @@ func ZehnderVersionDecode(val uint32) []int {
 
 type ZehnderDevice struct {
 	Name      string
-	NodeID    byte
 	Connected bool
 
 	DeviceInfo *ZehnderDeviceInfo
@@ -30,27 +31,69 @@ type ZehnderDevice struct {
 	routines       int
 	stopSignal     chan bool
 	frameQ         chan can.Frame
+	frames         sync.WaitGroup
 	pdoQ           chan can.Frame
 	rmiQ           chan can.Frame
 	txQ            chan can.Frame
 	heartbeatQ     chan can.Frame
 	rmiRequestQ    chan *ZehnderRMI
 	rmiCTS         chan bool
+	mu             sync.Mutex
 	pdoData        map[int]*PDOValue
+	subscriptions  map[int]*pdoSubscription
+	nodes          map[byte]*CANNode
+	online         bool
+	queryNodes     bool
+	collision      bool
+	collisionTime  time.Time
+	nodeID         atomic.Uint32
+	autoNodeID     bool
+	filter         FilterSettings
+	conditions     map[string]*StatusCondition
+	statusRules    map[string]statusRule
+	errorPoll      ErrorSettings
+	stopped        chan bool
+	statusEvents   []StatusEvent
+	statusQ        chan StatusEvent
+	nodeIDFrom     byte
+	nodeIDTo       byte
+	pipeline       *reading.Pipeline
+	rmiMu          sync.Mutex
 	rmiCbFn        func(*ZehnderRMI)
 	defaultRMICbFn func(*ZehnderRMI)
-	rmiSequence    byte
+	rmiSequence    atomic.Uint32
 	captureFh      *os.File
 	doCapture      bool
 }
 
 func NewZehnderDevice(id byte) *ZehnderDevice {
-	return &ZehnderDevice{
-		NodeID:     id,
-		pdoData:    make(map[int]*PDOValue),
-		Name:       "Zehnder MVHR",
-		DeviceInfo: NewZehnderDeviceInfo(),
+	dev := &ZehnderDevice{
+		pdoData:       make(map[int]*PDOValue),
+		subscriptions: make(map[int]*pdoSubscription),
+		nodes:         make(map[byte]*CANNode),
+		conditions:    make(map[string]*StatusCondition),
+		online:        true,
+		Name:          "Zehnder MVHR",
+		DeviceInfo:    NewZehnderDeviceInfo(),
 	}
+	dev.setNodeID(id)
+	return dev
+}
+
+// NodeID returns the node id the device is using on the bus, which is 0
+// while a free id is being chosen.
+func (dev *ZehnderDevice) NodeID() byte {
+	return byte(dev.nodeID.Load())
+}
+
+// setNodeID changes the node id. It is read without holding dev.mu by the
+// heartbeat and RMI routines, so is kept in an atomic.
+func (dev *ZehnderDevice) setNodeID(id byte) {
+	dev.nodeID.Store(uint32(id))
+}
+
+func (dev *ZehnderDevice) SetPipeline(p *reading.Pipeline) {
+	dev.pipeline = p
 }
 
 func (dev *ZehnderDevice) SetDefaultRMICallback(fn func(*ZehnderRMI)) {
@@ -67,20 +110,22 @@ func (dev *ZehnderDevice) Disconnect() error {
 
 func (dev *ZehnderDevice) Start() error {
 	dev.stopSignal = make(chan bool, 2)
+	dev.stopped = make(chan bool)
 	dev.frameQ = make(chan can.Frame)
 	dev.pdoQ = make(chan can.Frame)
 	dev.rmiQ = make(chan can.Frame)
 	dev.txQ = make(chan can.Frame)
 	dev.heartbeatQ = make(chan can.Frame)
 	dev.rmiRequestQ = make(chan *ZehnderRMI)
-	dev.rmiCTS = make(chan bool)
+	dev.rmiCTS = make(chan bool, 1)
 
 	go dev.processFrame()
 	go dev.processPDOFrame()
 	go dev.processRMIFrame()
 	go dev.processRMIQueue()
 	go dev.heartbeat()
-	dev.routines = 5
+	go dev.watchdog()
+	dev.routines = 6
 
 	if dev.connection.device != nil {
 		log.Println("Starting network services")
@@ -88,15 +133,31 @@ func (dev *ZehnderDevice) Start() error {
 		// don't include in the numbers...
 		go dev.receiver()
 		go dev.transmitter()
-		dev.rmiCTS <- true
-		dev.routines = 6
+		dev.routines = 7
+	}
+
+	if dev.autoNodeID {
+		if err := dev.chooseNodeID(); err != nil {
+			dev.Stop()
+			return err
+		}
+	}
+
+	dev.loadStatusRules()
+	if dev.connection.device != nil && len(dev.errorPoll.Props) > 0 {
+		// The poller waits on the stopped channel rather than taking a
+		// stop signal, as it may be waiting for an RMI response. The
+		// socket is opened by the network routines, so the first poll
+		// is made after the first interval.
+		dev.wg.Add(1)
+		go dev.pollErrors()
 	}
 
 	return nil
 }
 
 func (dev *ZehnderDevice) hasNetwork() bool {
-	return dev.connection.conn != nil
+	return dev.connection.isOpen()
 }
 
 func (dev *ZehnderDevice) Wait() {
@@ -104,15 +165,17 @@ func (dev *ZehnderDevice) Wait() {
 }
 
 func (dev *ZehnderDevice) Stop() {
+	close(dev.stopped)
 	for n := 0; n < dev.routines; n++ {
 		dev.stopSignal <- true
 	}
 }
 
+// CaptureAll records every frame received, in the candump -L log format, to
+// the file.
 func (dev *ZehnderDevice) CaptureAll(fn string) error {
 	f, err := os.Create(fn)
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
 	dev.captureFh = f
@@ -120,38 +183,10 @@ func (dev *ZehnderDevice) CaptureAll(fn string) error {
 	return nil
 }
 
-func (dev *ZehnderDevice) ProcessDumpFile(filename string) (err error) {
-	info, err := os.Stat(filename)
-	if os.IsNotExist(err) {
-		fmt.Printf("File does not exist: %s. Error: %s\n", filename, err)
-		return err
-	}
-	fmt.Printf("File: %s. Total size is %v bytes\n", filename, info.Size())
-	if info.Size() == 0 {
-		fmt.Println("File has 0 bytes. Nothing to do")
-		return fmt.Errorf("file has zero size. Nothing to do")
-	}
-
-	readFile, err := os.Open(filename)
-
-	if err != nil {
-		fmt.Println(err)
-		return err
-	}
-	fileScanner := bufio.NewScanner(readFile)
-
-	fileScanner.Split(bufio.ScanLines)
-
-	for fileScanner.Scan() {
-		fmt.Print(".")
-		frame := can.Frame{}
-		frame.UnmarshalString(fileScanner.Text())
-		dev.frameQ <- frame
-	}
-	fmt.Println()
-
-	readFile.Close()
-	return err
+// ProcessDumpFile passes all the frames from a capture through the device as
+// fast as they can be processed.
+func (dev *ZehnderDevice) ProcessDumpFile(filename string) error {
+	return dev.ReplayDumpFile(filename, 0)
 }
 
 type pair struct {
@@ -167,9 +202,20 @@ func (p pairList) Less(i, j int) bool { return p[i].value.Sensor.Name < p[j].val
 func (dev *ZehnderDevice) JsonResponse() map[string]interface{} {
 	dataMap := make(map[string]interface{})
 
+	dev.mu.Lock()
 	for _, v := range dev.pdoData {
 		dataMap[v.Sensor.slug] = v.GetData()
 	}
+	if stale := dev.stalePDOs(); len(stale) > 0 {
+		dataMap["stale"] = stale
+	}
+	online := dev.online
+	dev.mu.Unlock()
+
+	dev.pipeline.Apply(dataMap)
+	if !online {
+		dataMap["error"] = "no heartbeat from the unit"
+	}
 	return dataMap
 }
 
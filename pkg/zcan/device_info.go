@@ -1,6 +1,9 @@
 package zcan
 
-import "log"
+import (
+	"log"
+	"time"
+)
 
 type ZehnderDeviceInfo struct {
 	Model           string
@@ -65,27 +68,40 @@ func (zdi *ZehnderDeviceInfo) storeDeviceInfo(rmi *ZehnderRMI) {
 	zdi.syncer <- true
 }
 
-func (zdi *ZehnderDeviceInfo) startUpdate(dev *ZehnderDevice) {
-	log.Println("attempting to update device information")
-	dest := NewZehnderDestination(1, 1, 1)
+func (zdi *ZehnderDeviceInfo) startUpdate(dev *ZehnderDevice, node byte) {
+	log.Printf("attempting to update device information for node %d", node)
+	dest := NewZehnderDestination(node, 1, 1)
 	dest.GetMultiple(dev, []byte{4, 6, 8, 0x0B, 0x0D, 0x14}, ZehnderRMITypeActualValue, zdi.storeDeviceInfo)
 }
 
 func (dev *ZehnderDevice) JsonDeviceInfo() map[string]interface{} {
 	dataMap := make(map[string]interface{})
 	if dev.DeviceInfo.DeviceName == "" {
-		dev.DeviceInfo.startUpdate(dev)
-		rv := <-dev.DeviceInfo.syncer
-		if !rv {
-			log.Printf("unable to get device information")
+		dev.DeviceInfo.startUpdate(dev, unitNodeID)
+		select {
+		case rv := <-dev.DeviceInfo.syncer:
+			if !rv {
+				log.Printf("unable to get device information")
+				return dataMap
+			}
+		case <-time.After(rmiTimeout):
+			log.Printf("no response to device information request")
 			return dataMap
 		}
 	}
-	dataMap["model"] = dev.DeviceInfo.Model
-	dataMap["serial_number"] = dev.DeviceInfo.SerialNumber
-	dataMap["software_version"] = dev.DeviceInfo.SoftwareVersion
-	dataMap["article_number"] = dev.DeviceInfo.ArticleNumber
-	dataMap["country_code"] = dev.DeviceInfo.CountryCode
-	dataMap["device_name"] = dev.DeviceInfo.DeviceName
+	dataMap = dev.DeviceInfo.jsonMap()
+	dataMap["node_id"] = dev.NodeID()
+	dataMap["node_id_auto"] = dev.autoNodeID
 	return dataMap
 }
+
+func (zdi *ZehnderDeviceInfo) jsonMap() map[string]interface{} {
+	return map[string]interface{}{
+		"model":            zdi.Model,
+		"serial_number":    zdi.SerialNumber,
+		"software_version": zdi.SoftwareVersion,
+		"article_number":   zdi.ArticleNumber,
+		"country_code":     zdi.CountryCode,
+		"device_name":      zdi.DeviceName,
+	}
+}
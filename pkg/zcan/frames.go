@@ -1,10 +1,20 @@
 package zcan
 
 import (
-	"fmt"
 	"log"
+	"time"
+
+	"go.einride.tech/can"
 )
 
+// queueFrame passes a received frame for decoding. Each frame is counted in
+// dev.frames until it has been handled, so a replay can wait for every frame
+// to be decoded.
+func (dev *ZehnderDevice) queueFrame(frame can.Frame) {
+	dev.frames.Add(1)
+	dev.frameQ <- frame
+}
+
 func (dev *ZehnderDevice) processFrame() {
 	dev.wg.Add(1)
 	defer dev.wg.Done()
@@ -14,7 +24,7 @@ loop:
 		select {
 		case frame := <-dev.frameQ:
 			if dev.doCapture {
-				dev.captureFh.WriteString(fmt.Sprintf("%s\n", frame))
+				dev.captureFh.WriteString(FormatCandump(time.Now(), dev.captureInterface(), frame) + "\n")
 			}
 			ck := frame.ID >> 24
 			switch ck {
@@ -26,6 +36,7 @@ loop:
 				dev.heartbeatQ <- frame
 			default:
 				log.Printf("Unknown frame MSB: %02X", ck)
+				dev.frames.Done()
 			}
 		case <-dev.stopSignal:
 			break loop
@@ -36,3 +47,10 @@ loop:
 		dev.captureFh.Close()
 	}
 }
+
+func (dev *ZehnderDevice) captureInterface() string {
+	if dev.connection.interfaceName != "" {
+		return dev.connection.interfaceName
+	}
+	return "can0"
+}
@@ -7,37 +7,42 @@ import (
 )
 
 func (dev *ZehnderDevice) makeHeartbeatFrame() can.Frame {
-	id := uint32(0x10000000 + uint32(dev.NodeID))
+	id := uint32(0x10000000 + uint32(dev.NodeID()))
 	return can.Frame{ID: id, IsExtended: true}
 }
 
+// sendHeartbeat sends our heartbeat, unless we are still choosing a node id.
+func (dev *ZehnderDevice) sendHeartbeat() {
+	if dev.hasNetwork() && dev.NodeID() != 0 {
+		dev.txQ <- dev.makeHeartbeatFrame()
+	}
+}
+
 func (dev *ZehnderDevice) heartbeat() {
 	dev.wg.Add(1)
 
-	if dev.hasNetwork() {
-		dev.txQ <- dev.makeHeartbeatFrame()
-	}
+	dev.sendHeartbeat()
 	timer := time.NewTicker(2 * time.Second)
 
 loop:
 	for {
 		select {
 		case frame := <-dev.heartbeatQ:
-			if frame.IsRemote {
-				nodeId := frame.ID & 0x3F
-				if nodeId == uint32(dev.NodeID) {
-					if dev.hasNetwork() {
-						dev.txQ <- dev.makeHeartbeatFrame()
-					}
-					timer.Reset(2 * time.Second)
-				}
+			if !frame.IsRemote {
+				dev.nodeSeen(byte(frame.ID&0x3F), time.Now())
+				dev.frames.Done()
+				continue
 			}
+			nodeId := frame.ID & 0x3F
+			if nodeId == uint32(dev.NodeID()) {
+				dev.sendHeartbeat()
+				timer.Reset(2 * time.Second)
+			}
+			dev.frames.Done()
 		case <-dev.stopSignal:
 			break loop
 		case <-timer.C:
-			if dev.hasNetwork() {
-				dev.txQ <- dev.makeHeartbeatFrame()
-			}
+			dev.sendHeartbeat()
 		}
 	}
 	timer.Stop()
@@ -14,8 +14,7 @@ func (dev *ZehnderDevice) receiver() {
 
 	recv := dev.connection.getReceiver()
 	for recv.Receive() {
-		frame := recv.Frame()
-		dev.frameQ <- frame
+		dev.queueFrame(recv.Frame())
 	}
 	dev.connection.close()
 }
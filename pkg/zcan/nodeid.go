@@ -0,0 +1,66 @@
+package zcan
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// How long to listen for heartbeats before choosing a node id.
+const nodeIDListenTime = 5 * time.Second
+
+// SetAutoNodeID sets the device to choose a free node id in the range when
+// started, rather than using a fixed id. The id is changed again if another
+// device is later seen using it.
+func (dev *ZehnderDevice) SetAutoNodeID(from, to byte) error {
+	if from < 1 || to > 0x3F || from > to {
+		return fmt.Errorf("invalid node id range %d - %d", from, to)
+	}
+	dev.autoNodeID = true
+	dev.nodeIDFrom = from
+	dev.nodeIDTo = to
+	dev.setNodeID(0)
+	return nil
+}
+
+// freeNodeID returns the first id in the range that has not been seen on the
+// bus, or 0 if there are none. dev.mu must be held.
+func (dev *ZehnderDevice) freeNodeID(exclude byte) byte {
+	for id := dev.nodeIDFrom; id <= dev.nodeIDTo && id != 0; id++ {
+		if _, ck := dev.nodes[id]; ck || id == exclude {
+			continue
+		}
+		return id
+	}
+	return 0
+}
+
+// chooseNodeID listens for heartbeats and then picks a free node id.
+func (dev *ZehnderDevice) chooseNodeID() error {
+	log.Printf("%s: listening for %s to find a free node id", dev.Name, nodeIDListenTime)
+	time.Sleep(nodeIDListenTime)
+
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	id := dev.freeNodeID(0)
+	if id == 0 {
+		return fmt.Errorf("no free node id in the range %d - %d", dev.nodeIDFrom, dev.nodeIDTo)
+	}
+	dev.setNodeID(id)
+	log.Printf("%s: using node id %d", dev.Name, id)
+	return nil
+}
+
+// renegotiateNodeID picks a new node id after another device has been seen
+// using ours. dev.mu must be held.
+func (dev *ZehnderDevice) renegotiateNodeID() {
+	current := dev.NodeID()
+	id := dev.freeNodeID(current)
+	if id == 0 {
+		log.Printf("%s: no free node id to move to from %d", dev.Name, current)
+		return
+	}
+	log.Printf("%s: node id %d is in use by another device, changing to %d", dev.Name, current, id)
+	dev.setNodeID(id)
+	dev.collision = false
+}
@@ -0,0 +1,152 @@
+package zcan
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CANNode is a node that has been seen sending heartbeats on the bus.
+type CANNode struct {
+	NodeID     byte
+	FirstSeen  time.Time
+	LastSeen   time.Time
+	Heartbeats int
+	Interval   time.Duration
+	Role       string
+	Info       *ZehnderDeviceInfo
+}
+
+// The ComfoAir unit always uses node 1, other roles are inferred from the
+// model reported by the node.
+const unitNodeID = 1
+
+var modelRoles = []struct {
+	prefix string
+	role   string
+}{
+	{"comfoair", "ComfoAir unit"},
+	{"comfosense", "ComfoSense"},
+	{"comfoconnect", "ComfoConnect LAN C"},
+	{"comfocool", "ComfoCool"},
+	{"comfofond", "ComfoFond"},
+	{"option box", "Option box"},
+	{"comfocontrol", "ComfoControl"},
+}
+
+func roleFromModel(model string) string {
+	model = strings.ToLower(model)
+	for _, mr := range modelRoles {
+		if strings.HasPrefix(model, mr.prefix) {
+			return mr.role
+		}
+	}
+	return ""
+}
+
+func (dev *ZehnderDevice) SetQueryNodes(query bool) {
+	dev.queryNodes = query
+}
+
+// nodeSeen updates the node table when a heartbeat is received.
+func (dev *ZehnderDevice) nodeSeen(id byte, now time.Time) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	if id == dev.NodeID() && dev.autoNodeID {
+		dev.renegotiateNodeID()
+	}
+	if id == dev.NodeID() {
+		if now.Sub(dev.collisionTime) > time.Minute {
+			log.Printf("%s: another device on the bus is using our node id %d", dev.Name, id)
+			dev.collisionTime = now
+		}
+		dev.collision = true
+		return
+	}
+
+	node, ck := dev.nodes[id]
+	if !ck {
+		node = &CANNode{NodeID: id, FirstSeen: now}
+		if id == unitNodeID {
+			node.Role = "ComfoAir unit"
+		}
+		dev.nodes[id] = node
+		log.Printf("%s: found node %d on the bus", dev.Name, id)
+		if dev.queryNodes && dev.hasNetwork() {
+			go dev.queryNode(id)
+		}
+	} else {
+		gap := now.Sub(node.LastSeen)
+		if node.Interval == 0 {
+			node.Interval = gap
+		} else {
+			node.Interval = (node.Interval*3 + gap) / 4
+		}
+	}
+	node.LastSeen = now
+	node.Heartbeats++
+}
+
+// queryNode requests the device information from the node over RMI.
+func (dev *ZehnderDevice) queryNode(id byte) {
+	info := NewZehnderDeviceInfo()
+	info.startUpdate(dev, id)
+	select {
+	case ok := <-info.syncer:
+		if !ok {
+			return
+		}
+	case <-time.After(30 * time.Second):
+		log.Printf("%s: no response to device information request for node %d", dev.Name, id)
+		return
+	}
+
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	if node, ck := dev.nodes[id]; ck {
+		node.Info = info
+		if role := roleFromModel(info.Model); role != "" {
+			node.Role = role
+		}
+	}
+}
+
+// JsonNodes returns the table of nodes seen on the bus.
+func (dev *ZehnderDevice) JsonNodes() map[string]interface{} {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	var ids []int
+	for id := range dev.nodes {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	now := time.Now()
+	var nodes []map[string]interface{}
+	for _, id := range ids {
+		node := dev.nodes[byte(id)]
+		nm := map[string]interface{}{
+			"node_id":            node.NodeID,
+			"first_seen":         node.FirstSeen.Format(time.RFC3339),
+			"last_seen":          node.LastSeen.Format(time.RFC3339),
+			"heartbeats":         node.Heartbeats,
+			"heartbeat_interval": node.Interval.Seconds(),
+			"online":             now.Sub(node.LastSeen) < heartbeatTimeout,
+		}
+		if node.Role != "" {
+			nm["role"] = node.Role
+		}
+		if node.Info != nil {
+			nm["device_info"] = node.Info.jsonMap()
+		}
+		nodes = append(nodes, nm)
+	}
+	return map[string]interface{}{
+		"node_id":   dev.NodeID(),
+		"collision": dev.collision,
+		"nodes":     nodes,
+	}
+}
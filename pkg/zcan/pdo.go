@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/zathras777/sensors/pkg/reading"
 	"go.einride.tech/can"
 )
 
@@ -24,8 +27,10 @@ loop:
 			msg := pdoFromFrame(frame)
 			if msg.pdoId == 0 {
 				log.Println("Ignoring PDO with an ID of 0")
+				dev.frames.Done()
 				continue
 			}
+			dev.mu.Lock()
 			pv, ck := dev.pdoData[int(msg.pdoId)]
 			if !ck {
 				sensor := findSensor(int(msg.pdoId), msg.length)
@@ -33,6 +38,17 @@ loop:
 				dev.pdoData[int(msg.pdoId)] = pv
 			}
 			pv.Value = msg.data[:msg.length]
+			now := time.Now()
+			dev.pdoReceived(int(msg.pdoId), now)
+			v, ok := reading.Float(pv.GetData())
+			if ok {
+				dev.updateStatus(pv.Sensor.slug, v, now)
+			}
+			dev.mu.Unlock()
+			if ok {
+				dev.pipeline.Process(pv.Sensor.slug, v)
+			}
+			dev.frames.Done()
 		case <-dev.stopSignal:
 			break loop
 		}
@@ -55,30 +71,19 @@ func pdoFromFrame(frame can.Frame) pdoMessage {
 	}
 }
 
+// RequestPDO asks the unit to send the PDO at the interval. The request is
+// repeated if the PDO stops being received.
 func (dev *ZehnderDevice) RequestPDO(prod byte, pdo uint16, interval byte) {
-	canid := uint32(pdo&0x7ff)<<14 + uint32(0x40+prod)
-	frame := can.Frame{ID: canid, IsExtended: true, IsRemote: true}
-	copy(frame.Data[:], []byte{interval})
-	frame.Length = 1
-	dev.txQ <- frame
+	sub := dev.subscribe(prod, pdo, interval)
+	dev.txQ <- sub.frame()
 }
 
 func (dev *ZehnderDevice) RequestPDOBySlug(prod byte, pdoSlug string, interval byte) error {
-	var pdo uint16 = 0
-	for id, poss := range sensorData {
-		if poss.slug == strings.ToLower(pdoSlug) {
-			pdo = uint16(id)
-			break
-		}
-	}
-	if pdo == 0 {
+	id, _, ck := sensorBySlug(pdoSlug)
+	if !ck {
 		return fmt.Errorf("no matching PDO found for '%s'", pdoSlug)
 	}
-	canid := uint32(pdo&0x7ff)<<14 + uint32(0x40+prod)
-	frame := can.Frame{ID: canid, IsExtended: true, IsRemote: true}
-	copy(frame.Data[:], []byte{interval})
-	frame.Length = 1
-	dev.txQ <- frame
+	dev.RequestPDO(prod, uint16(id), interval)
 	return nil
 }
 
@@ -112,8 +117,9 @@ const (
 	CN_INT16 //3412 = 1234
 	CN_INT64
 	CN_STRING
-	CN_TIME
+	CN_TIME // seconds since 2000-01-01
 	CN_VERSION
+	CN_INT32
 )
 
 type PDOSensor struct {
@@ -129,60 +135,33 @@ type PDOValue struct {
 	Value  []byte
 }
 
-var sensorData = map[int]PDOSensor{
-	16:  {"Device state", "device_state", UNIT_UNKNOWN, CN_UINT8, 0},
-	33:  {"Preset", "preset", UNIT_UNKNOWN, CN_UINT8, 0},
-	49:  {"Operating Mode", "operating_mode", UNIT_UNKNOWN, CN_INT8, 0},
-	54:  {"Supply Fan Mode", "supply_fan_mode", UNIT_UNKNOWN, CN_UINT8, 0},
-	55:  {"Exhaust Fan Mode", "exhaust_fan_mode", UNIT_UNKNOWN, CN_UINT8, 0},
-	56:  {"Manual Mode", "manual_mode", UNIT_UNKNOWN, CN_UINT8, 0},
-	65:  {"Fan Speed Setting", "fan_speed_setting", UNIT_UNKNOWN, CN_INT8, 0},
-	66:  {"Bypass activation mode", "bypass_activation_mode", UNIT_UNKNOWN, CN_UINT8, 0},
-	67:  {"Temperature profile", "temperature_profile", UNIT_UNKNOWN, CN_UINT8, 0},
-	70:  {"Supply Fan Mode", "supply_fan_mode_2", UNIT_UNKNOWN, CN_UINT8, 0},
-	71:  {"Exhaust Fan Mode", "exhaust_fan_mode_2", UNIT_UNKNOWN, CN_UINT8, 0},
-	81:  {"Boost Period Remaining", "boost_period_remaining", UNIT_SECONDS, CN_UINT32, 0},
-	82:  {"Bypass Next Change", "bypass_next_change", UNIT_SECONDS, CN_UINT32, 0},
-	86:  {"Supply Fan Next Change", "supply_fan_next_change", UNIT_SECONDS, CN_UINT32, 0},
-	87:  {"Exhaust Fan Next Change", "exhaust_fan_next_change", UNIT_SECONDS, CN_UINT32, 0},
-	117: {"Exhaust Fan Duty", "exhaust_fan_duty", UNIT_PERCENT, CN_UINT8, 0},
-	118: {"Supply Fan Duty", "supply_fan_duty", UNIT_PERCENT, CN_UINT8, 0},
-	119: {"Exhaust Fan Flow", "exhaust_fan_flow", UNIT_M3H, CN_UINT16, 0},
-	120: {"Supply Fan Flow", "supply_fan_flow", UNIT_M3H, CN_UINT16, 0},
-	121: {"Exhaust Fan Speed", "exhaust_fan_speed", UNIT_RPM, CN_UINT16, 0},
-	122: {"Supply Fan Speed", "supply_fan_speed", UNIT_RPM, CN_UINT16, 0},
-	128: {"Power Consumption", "power_consumption", UNIT_WATT, CN_UINT16, 0},
-	130: {"Power Consumption Total", "power_consumption_total", UNIT_KWH, CN_UINT16, 0},
-	145: {"Preheater Power Consumption Total", "preheater_power_consumption_total", UNIT_KWH, CN_UINT16, 0},
-	146: {"Preheater Power Consumption", "preheater_power_consumption", UNIT_WATT, CN_UINT16, 0},
-	192: {"Filter Replacement Days", "filter_replacement_days", UNIT_DAYS, CN_UINT16, 0},
-	209: {"RMOT", "rmot", UNIT_CELCIUS, CN_UINT16, 1},
-	210: {"Heating season active", "heating_season_active", UNIT_UNKNOWN, CN_BOOL, 0},
-	211: {"Cooling season active", "cooling_season_active", UNIT_UNKNOWN, CN_BOOL, 0},
-	212: {"Temperature profile target", "temperature_profile_target", UNIT_CELCIUS, CN_UINT8, 1},
-	213: {"Avoided Heating Actual", "avoided_heating_actual", UNIT_WATT, CN_UINT16, 2},
-	214: {"Avoided Heating YTD", "avoided_heating_ytd", UNIT_KWH, CN_UINT16, 0},
-	220: {"Preheated Air Temperature (pre Heating)", "preheated_air_temperature_(pre_heating)", UNIT_CELCIUS, CN_UINT16, 1},
-	221: {"Preheated Air Temperature (post Heating)", "preheated_air_temperature_(post_heating)", UNIT_CELCIUS, CN_UINT16, 1},
-	225: {"Sensor based ventilation mode", "sensor_based_ventilation_mode", UNIT_UNKNOWN, CN_UINT8, 0},
-	226: {"Modulated fan speed", "modulated_fan_speed", UNIT_UNKNOWN, CN_UINT16, 0},
-	227: {"Bypass State", "bypass_state", UNIT_PERCENT, CN_UINT8, 0},
-	274: {"Extract Air Temperature", "extract_air_temperature", UNIT_CELCIUS, CN_UINT16, 1},
-	275: {"Exhaust Air Temperature", "exhaust_air_temperature", UNIT_CELCIUS, CN_UINT16, 1},
-	276: {"Outdoor Air Temperature", "outdoor_air_temperature", UNIT_CELCIUS, CN_UINT16, 1},
-	277: {"Preheated Outside Air Temperature", "preheated_outside_air_temperature", UNIT_CELCIUS, CN_UINT16, 1},
-	278: {"Supply Air Temperature", "supply_air_temperature", UNIT_CELCIUS, CN_UINT16, 1},
-	290: {"Extract Air Humidity", "extract_air_humidity", UNIT_PERCENT, CN_UINT8, 0},
-	291: {"Exhaust Air Humidity", "exhaust_air_humidity", UNIT_PERCENT, CN_UINT8, 0},
-	292: {"Outdoor Air Humidity", "outdoor_air_humidity", UNIT_PERCENT, CN_UINT8, 0},
-	293: {"Preheated Outdoor Air Humidity", "preheated_outdoor_air_humidity", UNIT_PERCENT, CN_UINT8, 0},
-	294: {"Supply Air Humidity", "supply_air_humidity", UNIT_PERCENT, CN_INT8, 0},
-	305: {"??? Supply CO2", "supply_co2", UNIT_UNKNOWN, CN_UINT8, 0},
-	306: {"?? Exhaust CO2", "exhaust_co2", UNIT_UNKNOWN, CN_UINT8, 0},
+// sensorData is the PDO catalogue, loaded from pdo_catalogue.yaml and any
+// additional catalogue files. It is guarded by sensorMu as unknown PDOs are
+// added as they are received.
+var sensorData = make(map[int]PDOSensor)
+var sensorMu sync.RWMutex
+
+func lookupSensor(pdo int) (PDOSensor, bool) {
+	sensorMu.RLock()
+	defer sensorMu.RUnlock()
+	sensor, ck := sensorData[pdo]
+	return sensor, ck
+}
+
+// sensorBySlug returns the PDO id and sensor with the slug.
+func sensorBySlug(pdoSlug string) (int, PDOSensor, bool) {
+	sensorMu.RLock()
+	defer sensorMu.RUnlock()
+	for id, poss := range sensorData {
+		if poss.slug == strings.ToLower(pdoSlug) {
+			return id, poss, true
+		}
+	}
+	return 0, PDOSensor{}, false
 }
 
 func findSensor(pdo int, dataLen int) PDOSensor {
-	sensor, ck := sensorData[pdo]
+	sensor, ck := lookupSensor(pdo)
 	if !ck {
 		log.Printf("unknown sensor 0x%02x [%d] %d bytes of data", pdo, pdo, dataLen)
 		sensorName := fmt.Sprintf("Unknown sensor %d", pdo)
@@ -192,51 +171,44 @@ func findSensor(pdo int, dataLen int) PDOSensor {
 		} else if dataLen == 4 {
 			sensor.DataType = CN_UINT32
 		}
+		sensorMu.Lock()
 		sensorData[pdo] = sensor
+		sensorMu.Unlock()
 	}
 	return sensor
 }
 
+// PDOUnits returns the units of the sensor with the given slug, or an empty
+// string if the slug is unknown.
+func PDOUnits(pdoSlug string) string {
+	_, sensor, _ := sensorBySlug(pdoSlug)
+	return sensor.Units
+}
+
 func (pv PDOValue) GetData() interface{} {
-	switch pv.Sensor.DataType {
-	case CN_BOOL:
-		return pv.Value[0] == 1
-	case CN_STRING:
-		rb := 0
-		var c byte
-		for rb, c = range pv.Value {
-			if c == 0 {
-				break
-			}
-		}
-		return string(pv.Value[:rb+1])
-	case CN_VERSION:
+	// PDO versions are reported as [major, minor] rather than the string
+	// returned by the decoder.
+	if pv.Sensor.DataType == CN_VERSION && len(pv.Value) >= 4 {
 		return ZehnderVersionDecode(binary.LittleEndian.Uint32(pv.Value))
-	case CN_UINT8, CN_UINT16, CN_UINT32:
-		val := pv.Number()
-		if pv.Sensor.DecimalPlaces > 0 {
-			return float64(val) / (float64(pv.Sensor.DecimalPlaces) * 10)
-		}
-		return val
-	case CN_INT8, CN_INT16, CN_INT64:
-		val := pv.SignedNumber()
-		if pv.Sensor.DecimalPlaces > 0 {
-			return float64(val) / (float64(pv.Sensor.DecimalPlaces) * 10)
-		}
-		return val
 	}
-	return "Unknown"
+	v, _, err := decodeValue(pv.Sensor.DataType, pv.Value)
+	if err != nil {
+		log.Printf("unable to decode %s: %s", pv.Sensor.Name, err)
+		return nil
+	}
+	return scaleValue(v, pv.Sensor.DecimalPlaces)
 }
 
 func (pv PDOValue) String() string {
 	s := fmt.Sprintf("%-45s0x%-8s", pv.Sensor.Name, strings.ToUpper(hex.EncodeToString(pv.Value)))
-	if pv.IsFloat() {
+	switch v := pv.GetData().(type) {
+	case float64:
 		fmtS := fmt.Sprintf("  %%6.%df", pv.Sensor.DecimalPlaces)
-		s += fmt.Sprintf(fmtS, pv.Float())
-	} else if pv.IsSigned() {
-		s += fmt.Sprintf("  %6d", pv.SignedNumber())
-	} else {
-		s += fmt.Sprintf("  %6d", pv.Number())
+		s += fmt.Sprintf(fmtS, v)
+	case int, uint:
+		s += fmt.Sprintf("  %6d", v)
+	default:
+		s += fmt.Sprintf("  %6v", v)
 	}
 	s += " " + pv.Sensor.Units
 	return s
@@ -246,41 +218,42 @@ func (pv PDOValue) IsBool() bool   { return pv.Sensor.DataType == CN_BOOL }
 func (pv PDOValue) IsString() bool { return pv.Sensor.DataType == CN_STRING }
 func (pv PDOValue) IsFloat() bool  { return pv.Sensor.DecimalPlaces > 0 }
 func (pv PDOValue) IsSigned() bool {
-	return pv.Sensor.DataType == CN_INT8 || pv.Sensor.DataType == CN_INT16 || pv.Sensor.DataType == CN_INT64
+	switch pv.Sensor.DataType {
+	case CN_INT8, CN_INT16, CN_INT32, CN_INT64:
+		return true
+	}
+	return false
 }
 
 func (pv PDOValue) Number() uint {
-	if pv.Sensor.DataType == CN_INT16 || pv.Sensor.DataType == CN_INT8 || pv.Sensor.DataType == CN_INT64 {
+	if pv.IsSigned() {
 		log.Println("attempt to get an unsigned number from a sensor with a signed data type?")
 		return 0
 	}
-	switch pv.Sensor.DataType {
-	case CN_UINT8:
-		return uint(pv.Value[0])
-	case CN_UINT16:
-		return uint(binary.LittleEndian.Uint16(pv.Value))
-	case CN_UINT32:
-		return uint(binary.LittleEndian.Uint32(pv.Value))
-	}
-	return 0
+	v, _, _ := decodeValue(pv.Sensor.DataType, pv.Value)
+	n, _ := v.(uint)
+	return n
 }
 
 func (pv PDOValue) SignedNumber() int {
-	if pv.Sensor.DataType == CN_UINT16 || pv.Sensor.DataType == CN_UINT8 || pv.Sensor.DataType == CN_UINT32 {
+	if !pv.IsSigned() {
 		log.Println("attempt to get an signed number from a sensor with an unsigned data type?")
 		return 0
 	}
-	switch pv.Sensor.DataType {
-	case CN_INT8:
-		return int(pv.Value[0])
-	case CN_INT16:
-		return int(binary.LittleEndian.Uint16(pv.Value))
-	case CN_INT64:
-		return int(binary.LittleEndian.Uint32(pv.Value))
-	}
-	return 0
+	v, _, _ := decodeValue(pv.Sensor.DataType, pv.Value)
+	n, _ := v.(int)
+	return n
 }
 
 func (pv PDOValue) Float() float64 {
-	return float64(pv.Number()) / (float64(pv.Sensor.DecimalPlaces) * 10)
+	v, _, _ := decodeValue(pv.Sensor.DataType, pv.Value)
+	switch n := scaleValue(v, pv.Sensor.DecimalPlaces).(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	case uint:
+		return float64(n)
+	}
+	return 0
 }
@@ -0,0 +1,84 @@
+package zcan
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// How long to wait for the response to an RMI request.
+const rmiTimeout = 5 * time.Second
+
+var ErrRMITimeout = errors.New("no response to RMI request")
+
+// RMIError is the error code returned by a node in an RMI error response.
+type RMIError struct {
+	Code byte
+}
+
+func (re RMIError) Error() string {
+	if desc, ck := errorDescriptions[re.Code]; ck {
+		return fmt.Sprintf("RMI error %d: %s", re.Code, desc)
+	}
+	return fmt.Sprintf("RMI error %d", re.Code)
+}
+
+// rmiWait queues a request and waits for the response.
+func (dev *ZehnderDevice) rmiWait(queue func(cbFn func(*ZehnderRMI))) (*ZehnderRMI, error) {
+	if !dev.hasNetwork() {
+		return nil, fmt.Errorf("not connected to the CAN bus")
+	}
+	ch := make(chan *ZehnderRMI, 1)
+	queue(func(rmi *ZehnderRMI) { ch <- rmi })
+
+	select {
+	case rmi := <-ch:
+		if rmi.IsError {
+			if rmi.DataLength > 0 {
+				return rmi, RMIError{rmi.Data[0]}
+			}
+			return rmi, RMIError{}
+		}
+		return rmi, nil
+	case <-time.After(rmiTimeout):
+		return nil, ErrRMITimeout
+	}
+}
+
+// Get requests a property and waits for the response. The value can be
+// decoded using GetData on the response.
+func (zr ZehnderDestination) Get(dev *ZehnderDevice, prop byte, flags ZehnderTypeFlag) (*ZehnderRMI, error) {
+	return dev.rmiWait(func(cbFn func(*ZehnderRMI)) {
+		zr.GetOne(dev, prop, flags, cbFn)
+	})
+}
+
+// Set sets a property and waits for the response.
+func (zr ZehnderDestination) Set(dev *ZehnderDevice, prop byte, value []byte) error {
+	_, err := dev.rmiWait(func(cbFn func(*ZehnderRMI)) {
+		zr.SetOne(dev, prop, value, cbFn)
+	})
+	return err
+}
+
+// encodeValue encodes an integer value as the type, little endian.
+func encodeValue(typ ZehnderType, v int) ([]byte, error) {
+	size := typ.Size()
+	switch typ {
+	case CN_BOOL, CN_UINT8, CN_UINT16, CN_UINT32:
+		if v < 0 || (size < 4 && v >= 1<<(size*8)) {
+			return nil, fmt.Errorf("%d is out of range", v)
+		}
+	case CN_INT8, CN_INT16, CN_INT32:
+		if v < -(1<<(size*8-1)) || v >= 1<<(size*8-1) {
+			return nil, fmt.Errorf("%d is out of range", v)
+		}
+	case CN_INT64:
+	default:
+		return nil, fmt.Errorf("unable to encode data type %d", typ)
+	}
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, uint64(v))
+	return buf[:size], nil
+}
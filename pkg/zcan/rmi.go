@@ -1,9 +1,9 @@
 package zcan
 
 import (
-	"encoding/binary"
 	"fmt"
 	"log"
+	"time"
 
 	"go.einride.tech/can"
 )
@@ -49,35 +49,44 @@ loop:
 	for {
 		select {
 		case frame := <-dev.rmiQ:
-			rmi := rmiFromFrame(frame)
-			//			log.Printf("RX: %v", frame)
-			if rmi.DestId != dev.NodeID {
-				if rmi.SourceId == dev.NodeID {
-					continue
-				}
-				log.Printf("Received RMI but it's not for us...%02X vs wanted %02X\n", rmi.DestId, dev.NodeID)
-				log.Printf("FRAME: %v\n", rmi)
-				continue
-			}
-			if rmi.IsMulti {
-				if holder != nil {
-					holder.appendRMI(rmi)
-				} else {
-					holder = rmi
-				}
-				if holder.finalSeen {
-					dev.doRMICallback(holder)
-					holder = nil
-				}
-			} else {
-				dev.doRMICallback(rmi)
-			}
+			holder = dev.handleRMIFrame(frame, holder)
+			dev.frames.Done()
 		case <-dev.stopSignal:
 			break loop
 		}
 	}
 }
 
+// handleRMIFrame decodes an RMI frame, calling the callback once the message
+// is complete. holder is the multi-frame message being assembled, and the
+// updated holder is returned.
+func (dev *ZehnderDevice) handleRMIFrame(frame can.Frame, holder *ZehnderRMI) *ZehnderRMI {
+	rmi := rmiFromFrame(frame)
+	//	log.Printf("RX: %v", frame)
+	nodeID := dev.NodeID()
+	if rmi.DestId != nodeID {
+		if rmi.SourceId != nodeID {
+			log.Printf("Received RMI but it's not for us...%02X vs wanted %02X\n", rmi.DestId, nodeID)
+			log.Printf("FRAME: %v\n", rmi)
+		}
+		return holder
+	}
+	if !rmi.IsMulti {
+		dev.doRMICallback(rmi)
+		return holder
+	}
+	if holder != nil {
+		holder.appendRMI(rmi)
+	} else {
+		holder = rmi
+	}
+	if holder.finalSeen {
+		dev.doRMICallback(holder)
+		return nil
+	}
+	return holder
+}
+
 var errorDescriptions = map[byte]string{
 	11: "Unknown Command",
 	12: "Unknown Unit",
@@ -95,9 +104,13 @@ func (dev *ZehnderDevice) doRMICallback(rmi *ZehnderRMI) {
 		log.Printf("error response RMI received: src 0x%02x dest 0x%02x seq %d: error code 0x%02x %s",
 			rmi.SourceId, rmi.DestId, rmi.Sequence, rmi.Data[0], errorDescriptions[rmi.Data[0]])
 	}
-	if dev.rmiCbFn != nil {
-		dev.rmiCbFn(rmi)
-		dev.rmiCbFn = nil
+	dev.rmiMu.Lock()
+	cbFn := dev.rmiCbFn
+	dev.rmiCbFn = nil
+	dev.rmiMu.Unlock()
+
+	if cbFn != nil {
+		cbFn(rmi)
 	} else if dev.defaultRMICbFn != nil {
 		log.Println("RMI message received. Processing using default handler")
 		dev.defaultRMICbFn(rmi)
@@ -105,40 +118,55 @@ func (dev *ZehnderDevice) doRMICallback(rmi *ZehnderRMI) {
 		log.Println("RMI message received, but no callback was set?")
 	}
 
-	if dev.hasNetwork() {
-		dev.rmiCTS <- true
+	select {
+	case dev.rmiCTS <- true:
+	default:
 	}
 }
 
+// nextSequence returns the sequence number for the next RMI request. Requests
+// are made from several goroutines, so the counter is kept in an atomic.
+func (dev *ZehnderDevice) nextSequence() byte {
+	return byte(dev.rmiSequence.Add(1)-1) & 0x03
+}
+
 func NewZehnderDestination(node byte, unit byte, subunit byte) ZehnderDestination {
 	return ZehnderDestination{node, unit, subunit}
 }
 
 func (zr ZehnderDestination) GetOne(dev *ZehnderDevice, prop byte, flags ZehnderTypeFlag, cbFn func(*ZehnderRMI)) {
-	rmi := ZehnderRMI{SourceId: dev.NodeID, DestId: zr.DestNodeId, IsRequest: true, Sequence: dev.rmiSequence}
+	rmi := ZehnderRMI{SourceId: dev.NodeID(), DestId: zr.DestNodeId, IsRequest: true, Sequence: dev.nextSequence()}
 	rmi.Data = []byte{0x01, zr.Unit, zr.SubUnit, byte(flags), prop}
 	rmi.DataLength = 5
 	rmi.callbackFn = cbFn
-	dev.rmiSequence = (dev.rmiSequence + 1) & 0x03
-	dev.rmiRequestQ <- &rmi
+	dev.queueRMI(&rmi)
 }
 
 func (zr ZehnderDestination) GetMultiple(dev *ZehnderDevice, props []byte, flags ZehnderTypeFlag, cbFn func(*ZehnderRMI)) {
-	rmi := ZehnderRMI{SourceId: dev.NodeID, DestId: zr.DestNodeId, IsRequest: true, Sequence: dev.rmiSequence}
+	rmi := ZehnderRMI{SourceId: dev.NodeID(), DestId: zr.DestNodeId, IsRequest: true, Sequence: dev.nextSequence()}
 	or_type := byte(flags) | byte(len(props))
 	rmi.Data = append([]byte{0x02, zr.Unit, zr.SubUnit, 1, or_type}, props...)
 	rmi.DataLength = len(rmi.Data)
 	rmi.callbackFn = cbFn
-	dev.rmiSequence = (dev.rmiSequence + 1) & 0x03
-	dev.rmiRequestQ <- &rmi
+	dev.queueRMI(&rmi)
 }
 
-func (zr ZehnderDestination) SetOne(dev *ZehnderDevice, prop byte, value []byte) {
-	// Untested
-	rmi := ZehnderRMI{SourceId: dev.NodeID, DestId: zr.DestNodeId, IsRequest: true, Sequence: dev.rmiSequence}
+func (zr ZehnderDestination) SetOne(dev *ZehnderDevice, prop byte, value []byte, cbFn func(*ZehnderRMI)) {
+	rmi := ZehnderRMI{SourceId: dev.NodeID(), DestId: zr.DestNodeId, IsRequest: true, Sequence: dev.nextSequence()}
 	rmi.Data = append([]byte{0x03, zr.Unit, zr.SubUnit, prop}, value...)
-	dev.rmiSequence = (dev.rmiSequence + 1) & 0x03
-	dev.rmiRequestQ <- &rmi
+	rmi.DataLength = len(rmi.Data)
+	rmi.callbackFn = cbFn
+	dev.queueRMI(&rmi)
+}
+
+// Command sends a command other than a property get or set to the unit and
+// subunit, with the arguments following the subunit.
+func (zr ZehnderDestination) Command(dev *ZehnderDevice, cmd byte, args []byte, cbFn func(*ZehnderRMI)) {
+	rmi := ZehnderRMI{SourceId: dev.NodeID(), DestId: zr.DestNodeId, IsRequest: true, Sequence: dev.nextSequence()}
+	rmi.Data = append([]byte{cmd, zr.Unit, zr.SubUnit}, args...)
+	rmi.DataLength = len(rmi.Data)
+	rmi.callbackFn = cbFn
+	dev.queueRMI(&rmi)
 }
 
 func rmiFromFrame(frame can.Frame) *ZehnderRMI {
@@ -190,7 +218,9 @@ func (zrmi ZehnderRMI) MakeCANId() uint32 {
 }
 
 func (zrmi *ZehnderRMI) send(dev *ZehnderDevice) error {
+	dev.rmiMu.Lock()
 	dev.rmiCbFn = zrmi.callbackFn
+	dev.rmiMu.Unlock()
 
 	if zrmi.DataLength > 8 {
 		zrmi.IsMulti = true
@@ -228,57 +258,40 @@ func (zrmi *ZehnderRMI) GetData(typ ZehnderType) (rv any, err error) {
 		return
 	}
 
-	data := zrmi.Data[zrmi.readPos:]
-
-	switch typ {
-	case CN_BOOL:
-		rv = data[0] == 1
-		zrmi.readPos++
-	case CN_STRING:
-		rb := 0
-		var c byte
-		for rb, c = range data {
-			if c == 0 {
-				break
-			}
-		}
-		rv = string(data[:rb])
-		zrmi.readPos += rb + 1
-	case CN_VERSION:
-		vers := ZehnderVersionDecode(binary.LittleEndian.Uint32(data))
-		rv = fmt.Sprintf("%d.%d", vers[0], vers[1])
-		zrmi.readPos += 4
-	case CN_UINT8:
-		rv = uint(data[0])
-		zrmi.readPos++
-	case CN_UINT16:
-		rv = uint(binary.LittleEndian.Uint16(data))
-		zrmi.readPos += 2
-	case CN_UINT32:
-		rv = uint(binary.LittleEndian.Uint32(data))
-		zrmi.readPos += 4
-	case CN_INT8:
-		rv = int(data[0])
-		zrmi.readPos++
-	case CN_INT16:
-		rv = int(binary.LittleEndian.Uint16(data))
-		zrmi.readPos += 2
-	case CN_INT64:
-		rv = int(binary.LittleEndian.Uint32(data))
-		zrmi.readPos += 8
-	}
+	rv, n, err := decodeValue(typ, zrmi.Data[zrmi.readPos:zrmi.DataLength])
+	zrmi.readPos += n
 	return
 }
 
+// queueRMI passes the request to the RMI queue, unless the device has been
+// stopped, in which case the request is dropped and the caller times out.
+func (dev *ZehnderDevice) queueRMI(rmi *ZehnderRMI) {
+	select {
+	case dev.rmiRequestQ <- rmi:
+	case <-dev.stopped:
+	}
+}
+
+// processRMIQueue sends queued RMI requests one at a time, waiting for the
+// response to each, or for rmiTimeout, before sending the next.
 func (dev *ZehnderDevice) processRMIQueue() {
 	dev.wg.Add(1)
 loop:
 	for {
 		select {
-		case <-dev.rmiCTS:
+		case rmi := <-dev.rmiRequestQ:
+			select {
+			case <-dev.rmiCTS:
+			default:
+			}
+			rmi.send(dev)
 			select {
-			case rmi := <-dev.rmiRequestQ:
-				rmi.send(dev)
+			case <-dev.rmiCTS:
+			case <-time.After(rmiTimeout):
+				log.Printf("no response to RMI request to node %d", rmi.DestId)
+				dev.rmiMu.Lock()
+				dev.rmiCbFn = nil
+				dev.rmiMu.Unlock()
 			case <-dev.stopSignal:
 				break loop
 			}
@@ -0,0 +1,36 @@
+package zcan
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestNextSequence(t *testing.T) {
+	dev := NewZehnderDevice(1)
+	for n := 0; n < 8; n++ {
+		if seq := dev.nextSequence(); seq != byte(n&0x03) {
+			t.Fatalf("request %d: sequence %d, expected %d", n, seq, n&0x03)
+		}
+	}
+
+	// concurrent requests must each take a different sequence number
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	counts := make(map[byte]int)
+	for n := 0; n < 400; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seq := dev.nextSequence()
+			mu.Lock()
+			counts[seq]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	for seq := byte(0); seq < 4; seq++ {
+		if counts[seq] != 100 {
+			t.Errorf("sequence %d used %d times, expected 100", seq, counts[seq])
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package zcan
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+const (
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+)
+
+// How many status events are kept.
+const maxStatusEvents = 50
+
+// statusRule raises a named condition when the value of a PDO is above or
+// below a limit. Rules are loaded from the conditions in the PDO catalogue
+// and copied to each device when it is started.
+type statusRule struct {
+	Name     string
+	Severity string
+	Slug     string
+	Above    *float64
+	Below    *float64
+	Message  string
+}
+
+func (sr statusRule) check(v float64) bool {
+	return (sr.Above != nil && v > *sr.Above) || (sr.Below != nil && v < *sr.Below)
+}
+
+// catalogueRules holds the conditions from the PDO catalogues. It is guarded
+// by sensorMu along with the rest of the catalogue.
+var catalogueRules = make(map[string]statusRule)
+
+// StatusCondition is an error or warning condition that is currently active.
+type StatusCondition struct {
+	Name     string    `json:"name"`
+	Severity string    `json:"severity"`
+	Message  string    `json:"message"`
+	Value    float64   `json:"value"`
+	Since    time.Time `json:"since"`
+}
+
+// StatusEvent records a condition being raised or cleared.
+type StatusEvent struct {
+	StatusCondition
+	Active bool      `json:"active"`
+	Time   time.Time `json:"time"`
+}
+
+// addStatusRule adds a catalogue condition. sensorMu must be held.
+func addStatusRule(sr statusRule) error {
+	if sr.Name == "" || sr.Slug == "" {
+		return fmt.Errorf("conditions require a name and slug")
+	}
+	if sr.Above == nil && sr.Below == nil {
+		return fmt.Errorf("condition %s requires above or below", sr.Name)
+	}
+	switch sr.Severity {
+	case "":
+		sr.Severity = SeverityWarning
+	case SeverityInfo, SeverityWarning, SeverityError:
+	default:
+		return fmt.Errorf("condition %s has an unknown severity '%s'", sr.Name, sr.Severity)
+	}
+	catalogueRules[sr.Name] = sr
+	return nil
+}
+
+// loadStatusRules copies the catalogue conditions to the device, so catalogues
+// loaded later do not change the rules of a running device.
+func (dev *ZehnderDevice) loadStatusRules() {
+	sensorMu.RLock()
+	rules := make(map[string]statusRule, len(catalogueRules))
+	for name, sr := range catalogueRules {
+		rules[name] = sr
+	}
+	sensorMu.RUnlock()
+
+	dev.mu.Lock()
+	dev.statusRules = rules
+	dev.mu.Unlock()
+}
+
+// SetStatusCallback sets a function to be called whenever a condition is
+// raised or cleared. The events are passed to the function in order from a
+// single goroutine, so a slow callback does not hold up decoding.
+func (dev *ZehnderDevice) SetStatusCallback(fn func(StatusEvent)) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	if dev.statusQ != nil {
+		close(dev.statusQ)
+		dev.statusQ = nil
+	}
+	if fn == nil {
+		return
+	}
+	q := make(chan StatusEvent, maxStatusEvents)
+	go func() {
+		for event := range q {
+			fn(event)
+		}
+	}()
+	dev.statusQ = q
+}
+
+// updateStatus checks the conditions that use the PDO. dev.mu must be held.
+func (dev *ZehnderDevice) updateStatus(slug string, v float64, now time.Time) {
+	for _, sr := range dev.statusRules {
+		if sr.Slug == slug {
+			dev.setCondition(sr.Name, sr.Severity, sr.Message, v, sr.check(v), now)
+		}
+	}
+}
+
+// setCondition raises or clears the named condition, recording an event and
+// calling the status callback when it changes. dev.mu must be held.
+func (dev *ZehnderDevice) setCondition(name, severity, message string, v float64, raise bool, now time.Time) {
+	cond, active := dev.conditions[name]
+	if raise == active {
+		if active {
+			cond.Value = v
+		}
+		return
+	}
+	if !active {
+		cond = &StatusCondition{Name: name, Severity: severity, Message: message, Value: v, Since: now}
+		dev.conditions[name] = cond
+		log.Printf("%s: %s %s: %s", dev.Name, severity, name, message)
+	} else {
+		cond.Value = v
+		delete(dev.conditions, name)
+		log.Printf("%s: %s %s has cleared", dev.Name, severity, name)
+	}
+	event := StatusEvent{*cond, !active, now}
+	dev.statusEvents = append(dev.statusEvents, event)
+	if len(dev.statusEvents) > maxStatusEvents {
+		dev.statusEvents = dev.statusEvents[1:]
+	}
+	if dev.statusQ != nil {
+		dev.statusQ <- event
+	}
+}
+
+// JsonErrors returns the active conditions and recent events.
+func (dev *ZehnderDevice) JsonErrors() map[string]interface{} {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	active := make([]StatusCondition, 0, len(dev.conditions))
+	counts := map[string]int{SeverityInfo: 0, SeverityWarning: 0, SeverityError: 0}
+	for _, cond := range dev.conditions {
+		active = append(active, *cond)
+		counts[cond.Severity]++
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Name < active[j].Name })
+	events := make([]StatusEvent, len(dev.statusEvents))
+	copy(events, dev.statusEvents)
+
+	return map[string]interface{}{
+		"active":   active,
+		"errors":   counts[SeverityError],
+		"warnings": counts[SeverityWarning],
+		"events":   events,
+	}
+}
@@ -0,0 +1,169 @@
+package zcan
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUpdateStatus(t *testing.T) {
+	dev := NewZehnderDevice(1)
+	dev.loadStatusRules()
+	events := make(chan StatusEvent, 10)
+	dev.SetStatusCallback(func(ev StatusEvent) { events <- ev })
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	steps := []struct {
+		days   float64
+		active []string
+	}{
+		{30, nil},
+		{10, []string{"filter_replace_soon"}},
+		{0, []string{"filter_replace_soon", "filter_replace"}},
+		{180, nil},
+	}
+	for n, st := range steps {
+		dev.mu.Lock()
+		dev.updateStatus("filter_replacement_days", st.days, now.Add(time.Duration(n)*time.Minute))
+		dev.mu.Unlock()
+		rv := dev.JsonErrors()
+		active := rv["active"].([]StatusCondition)
+		if len(active) != len(st.active) {
+			t.Fatalf("step %d: active %v, expected %v", n, active, st.active)
+		}
+		for _, name := range st.active {
+			if _, ck := dev.conditions[name]; !ck {
+				t.Errorf("step %d: %s is not active", n, name)
+			}
+		}
+	}
+	if got := len(dev.JsonErrors()["events"].([]StatusEvent)); got != 4 {
+		t.Errorf("%d events recorded, expected 4", got)
+	}
+	// the callback must see the events in the order they happened
+	expected := []struct {
+		name   string
+		active bool
+	}{
+		{"filter_replace_soon", true},
+		{"filter_replace", true},
+		{"", false},
+		{"", false},
+	}
+	for n, exp := range expected {
+		select {
+		case ev := <-events:
+			if ev.Active != exp.active || (exp.name != "" && ev.Name != exp.name) {
+				t.Errorf("event %d: %s active %v, expected %s active %v", n, ev.Name, ev.Active, exp.name, exp.active)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("only %d events passed to the callback, expected 4", n)
+		}
+	}
+}
+
+func TestStatusCallbackOrder(t *testing.T) {
+	dev := NewZehnderDevice(1)
+	events := make(chan StatusEvent, 1000)
+	dev.SetStatusCallback(func(ev StatusEvent) {
+		// a slow consumer must not let later events overtake earlier ones
+		time.Sleep(10 * time.Microsecond)
+		events <- ev
+	})
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for n := 0; n < 200; n++ {
+		dev.mu.Lock()
+		dev.setCondition("flapping", SeverityWarning, "flapping", float64(n), n%2 == 0, now)
+		dev.mu.Unlock()
+	}
+	for n := 0; n < 200; n++ {
+		select {
+		case ev := <-events:
+			if ev.Active != (n%2 == 0) || ev.Value != float64(n) {
+				t.Fatalf("event %d: active %v value %v out of order", n, ev.Active, ev.Value)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("only %d events passed to the callback", n)
+		}
+	}
+}
+
+func TestErrorSettings(t *testing.T) {
+	dev := NewZehnderDevice(1)
+	err := dev.SetErrorSettings(ErrorSettings{Props: []ErrorProperty{
+		{Prop: 7},
+		{Prop: 9, Name: "unit_fault", Severity: SeverityWarning, Message: "The unit is reporting a fault"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	es := dev.errorPoll
+	if es.SubUnit != 1 || es.Interval != 60 {
+		t.Errorf("subunit %d interval %d, expected the defaults of 1 and 60", es.SubUnit, es.Interval)
+	}
+	if es.Props[0].Name != "unit_error_7" || es.Props[0].Severity != SeverityError {
+		t.Errorf("got %+v, expected the default name and severity", es.Props[0])
+	}
+	if es.Props[1].Name != "unit_fault" || es.Props[1].Severity != SeverityWarning {
+		t.Errorf("got %+v, expected the configured name and severity", es.Props[1])
+	}
+	if err := dev.SetErrorSettings(ErrorSettings{Props: []ErrorProperty{{Prop: 1, Severity: "fatal"}}}); err == nil {
+		t.Error("expected an error for an unknown severity")
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	steps := []struct {
+		value  any
+		active bool
+	}{
+		{uint(0), false},
+		{uint(3), true},
+		{true, true},
+		{false, false},
+	}
+	for n, st := range steps {
+		code, ok := errorCode(st.value)
+		if !ok {
+			t.Fatalf("step %d: %v is not a code", n, st.value)
+		}
+		dev.mu.Lock()
+		dev.setErrorProperty(es.Props[1], code, now)
+		_, active := dev.conditions["unit_fault"]
+		dev.mu.Unlock()
+		if active != st.active {
+			t.Errorf("step %d: active %v, expected %v", n, active, st.active)
+		}
+	}
+	if _, ok := errorCode("fault"); ok {
+		t.Error("a string was accepted as an error code")
+	}
+}
+
+func TestStatusRulesPerDevice(t *testing.T) {
+	dev := NewZehnderDevice(1)
+	dev.loadStatusRules()
+	if _, ck := dev.statusRules["filter_replace"]; !ck {
+		t.Fatal("catalogue conditions were not copied to the device")
+	}
+
+	err := loadCatalogue([]byte(`
+conditions:
+  - {name: test_condition, slug: filter_replacement_days, below: 100}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		sensorMu.Lock()
+		delete(catalogueRules, "test_condition")
+		sensorMu.Unlock()
+	}()
+	if _, ck := dev.statusRules["test_condition"]; ck {
+		t.Error("a later catalogue changed the rules of an existing device")
+	}
+	other := NewZehnderDevice(2)
+	other.loadStatusRules()
+	if sr, ck := other.statusRules["test_condition"]; !ck || sr.Severity != SeverityWarning {
+		t.Errorf("got %+v, expected the new condition with the default severity", sr)
+	}
+}
@@ -0,0 +1,168 @@
+package zcan
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"go.einride.tech/can"
+)
+
+// A PDO is treated as stale when nothing has been received for 3 times the
+// requested interval, or minStaleTime if that is longer.
+const minStaleTime = 30 * time.Second
+
+// The unit is treated as offline when no heartbeat has been seen for this long.
+const heartbeatTimeout = 10 * time.Second
+
+type pdoSubscription struct {
+	prod      byte
+	pdo       uint16
+	interval  byte
+	requested time.Time
+	lastSeen  time.Time
+	stale     bool
+}
+
+func (sub *pdoSubscription) staleAfter() time.Duration {
+	return max(3*time.Duration(sub.interval)*time.Second, minStaleTime)
+}
+
+func (sub *pdoSubscription) frame() can.Frame {
+	canid := uint32(sub.pdo&0x7ff)<<14 + uint32(0x40+sub.prod)
+	frame := can.Frame{ID: canid, IsExtended: true, IsRemote: true}
+	copy(frame.Data[:], []byte{sub.interval})
+	frame.Length = 1
+	return frame
+}
+
+// subscribe records the PDO request so that it can be repeated if updates
+// stop arriving.
+func (dev *ZehnderDevice) subscribe(prod byte, pdo uint16, interval byte) *pdoSubscription {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	sub, ck := dev.subscriptions[int(pdo)]
+	if !ck {
+		sub = &pdoSubscription{prod: prod, pdo: pdo}
+		dev.subscriptions[int(pdo)] = sub
+	}
+	sub.prod = prod
+	sub.interval = interval
+	sub.requested = time.Now()
+	return sub
+}
+
+// sendFrame queues a frame for transmission, giving up if the transmitter
+// does not accept it, e.g. as it has been stopped.
+func (dev *ZehnderDevice) sendFrame(frame can.Frame) bool {
+	select {
+	case dev.txQ <- frame:
+		return true
+	case <-time.After(time.Second):
+		return false
+	}
+}
+
+func (dev *ZehnderDevice) pdoReceived(pdo int, now time.Time) {
+	sub, ck := dev.subscriptions[pdo]
+	if !ck {
+		return
+	}
+	if sub.stale {
+		log.Printf("%s: receiving PDO %d again", dev.Name, pdo)
+	}
+	sub.lastSeen = now
+	sub.stale = false
+}
+
+// unitOnline returns false if a subscribed unit has stopped sending its
+// heartbeat. dev.mu must be held.
+func (dev *ZehnderDevice) unitOnline(now time.Time) bool {
+	for _, sub := range dev.subscriptions {
+		node, ck := dev.nodes[sub.prod]
+		if ck && now.Sub(node.LastSeen) > heartbeatTimeout {
+			return false
+		}
+	}
+	return true
+}
+
+// checkSubscriptions marks PDOs as stale when updates have stopped, and
+// returns the subscriptions that need to be requested again. All are
+// requested again when the unit heartbeat returns after being lost.
+func (dev *ZehnderDevice) checkSubscriptions(now time.Time) []*pdoSubscription {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	online := dev.unitOnline(now)
+	if online != dev.online {
+		if online {
+			log.Printf("%s: unit heartbeat has returned, renewing PDO requests", dev.Name)
+		} else {
+			log.Printf("%s: no heartbeat from the unit for %s", dev.Name, heartbeatTimeout)
+		}
+	}
+	returned := online && !dev.online
+	dev.online = online
+
+	var resend []*pdoSubscription
+	for _, sub := range dev.subscriptions {
+		last := sub.lastSeen
+		if last.Before(sub.requested) {
+			last = sub.requested
+		}
+		if now.Sub(last) > sub.staleAfter() {
+			if !sub.stale && sub.lastSeen.IsZero() {
+				log.Printf("%s: no data received for PDO %d", dev.Name, sub.pdo)
+			} else if !sub.stale {
+				log.Printf("%s: no update for PDO %d since %s", dev.Name, sub.pdo, sub.lastSeen.Format(time.RFC3339))
+			}
+			sub.stale = true
+		}
+		if returned || (online && sub.stale && now.Sub(sub.requested) > sub.staleAfter()) {
+			sub.requested = now
+			resend = append(resend, sub)
+		}
+	}
+	return resend
+}
+
+// watchdog periodically checks that subscribed PDOs are still being received
+// and requests them again if not.
+func (dev *ZehnderDevice) watchdog() {
+	dev.wg.Add(1)
+	defer dev.wg.Done()
+
+	ticker := time.NewTicker(5 * time.Second)
+loop:
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, sub := range dev.checkSubscriptions(now) {
+				if dev.hasNetwork() && !dev.sendFrame(sub.frame()) {
+					log.Printf("%s: unable to request PDO %d", dev.Name, sub.pdo)
+				}
+			}
+		case <-dev.stopSignal:
+			break loop
+		}
+	}
+	ticker.Stop()
+}
+
+// stalePDOs returns the slugs of the subscribed PDOs that are not being
+// updated. dev.mu must be held.
+func (dev *ZehnderDevice) stalePDOs() []string {
+	var stale []string
+	for id, sub := range dev.subscriptions {
+		if !sub.stale {
+			continue
+		}
+		if sensor, ck := lookupSensor(id); ck {
+			stale = append(stale, sensor.slug)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
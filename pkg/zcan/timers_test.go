@@ -0,0 +1,112 @@
+package zcan
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeRMIDevice returns a device that appears to be connected to the bus,
+// with the RMI queue read by the test rather than sent.
+func fakeRMIDevice(t *testing.T) *ZehnderDevice {
+	t.Helper()
+	dev := NewZehnderDevice(5)
+	dev.stopped = make(chan bool)
+	dev.rmiRequestQ = make(chan *ZehnderRMI)
+	local, remote := net.Pipe()
+	dev.connection.conn = local
+	t.Cleanup(func() {
+		local.Close()
+		remote.Close()
+	})
+	return dev
+}
+
+func TestScheduleCommands(t *testing.T) {
+	// the payloads match those sent by aiocomfoconnect
+	tests := []struct {
+		name string
+		call func(dev *ZehnderDevice) error
+		data []byte
+	}{
+		{"fan speed away", func(dev *ZehnderDevice) error { return dev.SetFanSpeed(FanSpeedAway) },
+			[]byte{0x84, 0x15, 0x01, 0x01, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}},
+		{"fan speed high", func(dev *ZehnderDevice) error { return dev.SetFanSpeed(FanSpeedHigh) },
+			[]byte{0x84, 0x15, 0x01, 0x01, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x03}},
+		{"boost for 10 minutes", func(dev *ZehnderDevice) error { return dev.SetBoost(10 * time.Minute) },
+			[]byte{0x84, 0x15, 0x01, 0x06, 0x00, 0x00, 0x00, 0x00, 0x58, 0x02, 0x00, 0x00, 0x03}},
+		{"boost until cancelled", func(dev *ZehnderDevice) error { return dev.SetBoost(0) },
+			[]byte{0x84, 0x15, 0x01, 0x06, 0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0x03}},
+		{"cancel boost", func(dev *ZehnderDevice) error { return dev.CancelBoost() },
+			[]byte{0x85, 0x15, 0x01, 0x06}},
+		{"away for 2 hours", func(dev *ZehnderDevice) error { return dev.SetAway(2 * time.Hour) },
+			[]byte{0x84, 0x15, 0x01, 0x0B, 0x00, 0x00, 0x00, 0x00, 0x20, 0x1C, 0x00, 0x00, 0x00}},
+		{"cancel away", func(dev *ZehnderDevice) error { return dev.CancelAway() },
+			[]byte{0x85, 0x15, 0x01, 0x0B}},
+		{"bypass open for an hour", func(dev *ZehnderDevice) error { return dev.SetBypass(true, time.Hour) },
+			[]byte{0x84, 0x15, 0x02, 0x01, 0x00, 0x00, 0x00, 0x00, 0x10, 0x0E, 0x00, 0x00, 0x01}},
+		{"bypass closed", func(dev *ZehnderDevice) error { return dev.SetBypass(false, 0) },
+			[]byte{0x84, 0x15, 0x02, 0x01, 0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0x02}},
+		{"bypass auto", func(dev *ZehnderDevice) error { return dev.BypassAuto() },
+			[]byte{0x85, 0x15, 0x02, 0x01}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dev := fakeRMIDevice(t)
+			result := make(chan error, 1)
+			go func() { result <- tc.call(dev) }()
+
+			var rmi *ZehnderRMI
+			select {
+			case rmi = <-dev.rmiRequestQ:
+			case <-time.After(time.Second):
+				t.Fatal("no request queued")
+			}
+			if rmi.SourceId != 5 || rmi.DestId != unitNodeID || !rmi.IsRequest {
+				t.Errorf("request from %d to %d (request %v), expected 5 to %d", rmi.SourceId, rmi.DestId, rmi.IsRequest, unitNodeID)
+			}
+			if !bytes.Equal(rmi.Data, tc.data) || rmi.DataLength != len(tc.data) {
+				t.Errorf("sent % X, expected % X", rmi.Data, tc.data)
+			}
+			rmi.callbackFn(&ZehnderRMI{SourceId: unitNodeID, DestId: 5})
+			if err := <-result; err != nil {
+				t.Errorf("unexpected error %s", err)
+			}
+		})
+	}
+}
+
+func TestScheduleCommandErrors(t *testing.T) {
+	dev := fakeRMIDevice(t)
+	if err := dev.SetFanSpeed(FanSpeedHigh + 1); err == nil {
+		t.Error("expected an error for an invalid fan speed")
+	}
+
+	result := make(chan error, 1)
+	go func() { result <- dev.SetBoost(time.Minute) }()
+	rmi := <-dev.rmiRequestQ
+	rmi.callbackFn(&ZehnderRMI{IsError: true, Data: []byte{30}, DataLength: 1})
+	var re RMIError
+	if err := <-result; !errors.As(err, &re) || re.Code != 30 {
+		t.Errorf("error %v, expected RMI error 30", err)
+	}
+
+	if err := NewZehnderDevice(5).SetBoost(time.Minute); err == nil {
+		t.Error("expected an error without a connection")
+	}
+}
+
+func TestParseFanSpeed(t *testing.T) {
+	for n, name := range []string{"away", "Low", "MEDIUM", "high"} {
+		speed, err := ParseFanSpeed(name)
+		if err != nil || speed != FanSpeed(n) {
+			t.Errorf("%s: got %v %v, expected %d", name, speed, err, n)
+		}
+	}
+	if _, err := ParseFanSpeed("turbo"); err == nil {
+		t.Error("expected an error for an unknown speed")
+	}
+}
@@ -0,0 +1,130 @@
+package zcan
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrorProperty is an RMI property of the error unit that holds an error or
+// warning code. A value other than 0 raises the named condition, which is
+// cleared when the value returns to 0. The name defaults to
+// unit_error_<property> and the severity to error.
+type ErrorProperty struct {
+	Prop     byte
+	Name     string
+	Severity string
+	Message  string
+}
+
+// ErrorSettings gives the RMI properties of the error unit that are polled
+// for the error and warning state of the ComfoAir. The property numbers are
+// not documented, so none are polled unless they have been configured and
+// the conditions raised from the PDO catalogue are the only ones available
+// by default.
+type ErrorSettings struct {
+	SubUnit  byte
+	Props    []ErrorProperty
+	Type     ZehnderType
+	Interval int
+}
+
+const unitError = 0x03
+
+func (dev *ZehnderDevice) SetErrorSettings(es ErrorSettings) error {
+	if es.SubUnit == 0 {
+		es.SubUnit = 1
+	}
+	if es.Interval <= 0 {
+		es.Interval = 60
+	}
+	props := make([]ErrorProperty, len(es.Props))
+	for n, ep := range es.Props {
+		if ep.Name == "" {
+			ep.Name = fmt.Sprintf("unit_error_%d", ep.Prop)
+		}
+		switch ep.Severity {
+		case "":
+			ep.Severity = SeverityError
+		case SeverityInfo, SeverityWarning, SeverityError:
+		default:
+			return fmt.Errorf("error property %d has an unknown severity '%s'", ep.Prop, ep.Severity)
+		}
+		props[n] = ep
+	}
+	es.Props = props
+	dev.errorPoll = es
+	return nil
+}
+
+func (dev *ZehnderDevice) errorDestination() ZehnderDestination {
+	return NewZehnderDestination(unitNodeID, unitError, dev.errorPoll.SubUnit)
+}
+
+// pollErrors reads the error properties at the configured interval until the
+// device is stopped.
+func (dev *ZehnderDevice) pollErrors() {
+	defer dev.wg.Done()
+
+	ticker := time.NewTicker(time.Duration(dev.errorPoll.Interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			dev.checkErrors(now)
+		case <-dev.stopped:
+			return
+		}
+	}
+}
+
+// checkErrors reads each error property, raising or clearing its condition.
+// Properties that cannot be read leave the condition unchanged.
+func (dev *ZehnderDevice) checkErrors(now time.Time) {
+	for _, ep := range dev.errorPoll.Props {
+		rmi, err := dev.errorDestination().Get(dev, ep.Prop, ZehnderRMITypeActualValue)
+		if err != nil {
+			log.Printf("%s: unable to read error property %d: %s", dev.Name, ep.Prop, err)
+			continue
+		}
+		v, err := rmi.GetData(dev.errorPoll.Type)
+		if err != nil {
+			log.Printf("%s: unable to decode error property %d: %s", dev.Name, ep.Prop, err)
+			continue
+		}
+		code, ok := errorCode(v)
+		if !ok {
+			log.Printf("%s: error property %d is not a number", dev.Name, ep.Prop)
+			continue
+		}
+		dev.mu.Lock()
+		dev.setErrorProperty(ep, code, now)
+		dev.mu.Unlock()
+	}
+}
+
+// errorCode returns the decoded value of an error property as a number.
+func errorCode(v any) (float64, bool) {
+	switch n := v.(type) {
+	case uint:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case bool:
+		if n {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// setErrorProperty raises or clears the condition for the error property.
+// dev.mu must be held.
+func (dev *ZehnderDevice) setErrorProperty(ep ErrorProperty, code float64, now time.Time) {
+	msg := ep.Message
+	if msg == "" {
+		msg = fmt.Sprintf("the unit reported code %v in error property %d", code, ep.Prop)
+	}
+	dev.setCondition(ep.Name, ep.Severity, msg, code, code != 0, now)
+}
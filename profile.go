@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ModbusProfile is a register map for a model of device, loaded from a yaml
+// file in the profiles directory.
+type ModbusProfile struct {
+	Description string
+	Baudrate    int
+	Interval    int
+	Registers   ModbusRegisters
+}
+
+// loadModbusProfile loads the named profile. The name can be the path of a
+// yaml file, otherwise <name>.yaml is looked for in the profiles directory.
+func loadModbusProfile(name, dir string) (*ModbusProfile, error) {
+	fn := name
+	if !strings.HasSuffix(fn, ".yaml") && !strings.HasSuffix(fn, ".yml") {
+		fn = filepath.Join(dir, strings.ToLower(name)+".yaml")
+	}
+	dat, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load profile %s: %w", name, err)
+	}
+	var profile ModbusProfile
+	if err = yaml.Unmarshal(dat, &profile); err != nil {
+		return nil, fmt.Errorf("unable to parse profile %s: %w", name, err)
+	}
+	return &profile, nil
+}
+
+// overlayRegister returns the profile register with every field that is set
+// in the node register replacing the profile value. As unset fields can not be
+// told apart from zero values, an override can not set a field back to zero.
+func overlayRegister(reg, ovr ModbusRegister) ModbusRegister {
+	if ovr.Description != "" {
+		reg.Description = ovr.Description
+	}
+	if ovr.Typ != "" {
+		reg.Typ = ovr.Typ
+	}
+	if ovr.Register != 0 {
+		reg.Register = ovr.Register
+	}
+	if ovr.Factor != 0 {
+		reg.Factor = ovr.Factor
+	}
+	if ovr.Multiplier != 0 {
+		reg.Multiplier = ovr.Multiplier
+	}
+	if ovr.Offset != 0 {
+		reg.Offset = ovr.Offset
+	}
+	if ovr.Order != "" {
+		reg.Order = ovr.Order
+	}
+	if ovr.Length != 0 {
+		reg.Length = ovr.Length
+	}
+	if ovr.Labels != nil {
+		reg.Labels = ovr.Labels
+	}
+	if ovr.Interval != 0 {
+		reg.Interval = ovr.Interval
+	}
+	reg.Skip = ovr.Skip
+
+	cal := &reg.Calibration
+	if ovr.Calibration.Gain != nil {
+		cal.Gain = ovr.Calibration.Gain
+	}
+	if ovr.Calibration.Offset != 0 {
+		cal.Offset = ovr.Calibration.Offset
+	}
+	if ovr.Calibration.Polynomial != nil {
+		cal.Polynomial = ovr.Calibration.Polynomial
+	}
+	if ovr.Calibration.Table != nil {
+		cal.Table = ovr.Calibration.Table
+	}
+	if ovr.Units != "" {
+		reg.Units = ovr.Units
+	}
+	if ovr.OutputUnits != "" {
+		reg.OutputUnits = ovr.OutputUnits
+	}
+	if ovr.Filters != nil {
+		reg.Filters = ovr.Filters
+	}
+	return reg
+}
+
+// mergeRegisters returns the profile registers, with the fields given in any
+// node register sharing the tag overriding those of the profile, followed by
+// the remaining node registers. Registers marked to be skipped are removed.
+// The tags of node registers that are not in the profile but are skipped or
+// have no type, so were meant to change a profile register, are returned as
+// unknown.
+func mergeRegisters(profile, node []ModbusRegister) ([]ModbusRegister, []string) {
+	overrides := make(map[string]ModbusRegister)
+	for _, reg := range node {
+		overrides[reg.Tag] = reg
+	}
+
+	var merged []ModbusRegister
+	for _, reg := range profile {
+		if ovr, ck := overrides[reg.Tag]; ck {
+			reg = overlayRegister(reg, ovr)
+			delete(overrides, reg.Tag)
+		}
+		if !reg.Skip {
+			merged = append(merged, reg)
+		}
+	}
+	var unknown []string
+	for _, reg := range node {
+		if _, ck := overrides[reg.Tag]; !ck {
+			continue
+		}
+		if reg.Skip || reg.Typ == "" {
+			unknown = append(unknown, reg.Tag)
+			continue
+		}
+		merged = append(merged, reg)
+	}
+	return merged, unknown
+}
+
+// applyModbusProfile merges the profile for the node, if any, with the
+// registers and settings given for the node.
+func applyModbusProfile(node *ModbusNode, dir string) error {
+	var profile ModbusProfile
+	if node.Profile != "" {
+		p, err := loadModbusProfile(node.Profile, dir)
+		if err != nil {
+			return err
+		}
+		profile = *p
+	}
+	if node.Baudrate == 0 {
+		node.Baudrate = profile.Baudrate
+	}
+	if node.Interval == 0 {
+		node.Interval = profile.Interval
+	}
+	var unknownHolding, unknownInput []string
+	node.Registers.Holding, unknownHolding = mergeRegisters(profile.Registers.Holding, node.Registers.Holding)
+	node.Registers.Input, unknownInput = mergeRegisters(profile.Registers.Input, node.Registers.Input)
+	if unknown := append(unknownHolding, unknownInput...); node.Profile != "" && len(unknown) > 0 {
+		return fmt.Errorf("%s: registers %s are not in profile %s", node.Name, strings.Join(unknown, ", "), node.Profile)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func profileRegister() ModbusRegister {
+	return ModbusRegister{
+		Description: "Temperature Before Evaporation",
+		Tag:         "T05",
+		Typ:         "s16",
+		Register:    11,
+		Factor:      1,
+		Offset:      -100,
+		Order:       "abcd",
+		Labels:      map[int]string{0: "off"},
+		Interval:    5,
+		ReadingOptions: ReadingOptions{
+			Calibration: CalibrationConfig{Offset: 0.5},
+			Units:       "°C",
+		},
+	}
+}
+
+func TestOverlayRegister(t *testing.T) {
+	tests := []struct {
+		name   string
+		ovr    ModbusRegister
+		change func(reg *ModbusRegister)
+	}{
+		{"no fields", ModbusRegister{Tag: "T05"}, func(reg *ModbusRegister) {}},
+		{"description", ModbusRegister{Description: "Loft"}, func(reg *ModbusRegister) { reg.Description = "Loft" }},
+		{"type", ModbusRegister{Typ: "u16"}, func(reg *ModbusRegister) { reg.Typ = "u16" }},
+		{"register", ModbusRegister{Register: 12}, func(reg *ModbusRegister) { reg.Register = 12 }},
+		{"factor", ModbusRegister{Factor: 10}, func(reg *ModbusRegister) { reg.Factor = 10 }},
+		{"multiplier", ModbusRegister{Multiplier: 0.1}, func(reg *ModbusRegister) { reg.Multiplier = 0.1 }},
+		{"offset", ModbusRegister{Offset: -98}, func(reg *ModbusRegister) { reg.Offset = -98 }},
+		{"order", ModbusRegister{Order: "cdab"}, func(reg *ModbusRegister) { reg.Order = "cdab" }},
+		{"length", ModbusRegister{Length: 4}, func(reg *ModbusRegister) { reg.Length = 4 }},
+		{"labels", ModbusRegister{Labels: map[int]string{1: "on"}}, func(reg *ModbusRegister) { reg.Labels = map[int]string{1: "on"} }},
+		{"interval", ModbusRegister{Interval: 60}, func(reg *ModbusRegister) { reg.Interval = 60 }},
+		{"calibration gain", ModbusRegister{ReadingOptions: ReadingOptions{Calibration: CalibrationConfig{Gain: floatPtr(2)}}},
+			func(reg *ModbusRegister) { reg.Calibration.Gain = floatPtr(2) }},
+		{"calibration offset", ModbusRegister{ReadingOptions: ReadingOptions{Calibration: CalibrationConfig{Offset: -1}}},
+			func(reg *ModbusRegister) { reg.Calibration.Offset = -1 }},
+		{"calibration table", ModbusRegister{ReadingOptions: ReadingOptions{Calibration: CalibrationConfig{Table: [][]float64{{0, 1}, {10, 11}}}}},
+			func(reg *ModbusRegister) { reg.Calibration.Table = [][]float64{{0, 1}, {10, 11}} }},
+		{"output units", ModbusRegister{ReadingOptions: ReadingOptions{OutputUnits: "°F"}},
+			func(reg *ModbusRegister) { reg.OutputUnits = "°F" }},
+		{"filters", ModbusRegister{ReadingOptions: ReadingOptions{Filters: []FilterConfig{{Type: "median", Window: 3}}}},
+			func(reg *ModbusRegister) { reg.Filters = []FilterConfig{{Type: "median", Window: 3}} }},
+		{"skip", ModbusRegister{Skip: true}, func(reg *ModbusRegister) { reg.Skip = true }},
+		{"several", ModbusRegister{Offset: -98, ReadingOptions: ReadingOptions{Units: "K"}}, func(reg *ModbusRegister) {
+			reg.Offset = -98
+			reg.Units = "K"
+		}},
+	}
+	for _, tc := range tests {
+		want := profileRegister()
+		tc.change(&want)
+		if got := overlayRegister(profileRegister(), tc.ovr); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tc.name, got, want)
+		}
+	}
+}
+
+func tags(regs []ModbusRegister) []string {
+	var rv []string
+	for _, reg := range regs {
+		rv = append(rv, reg.Tag)
+	}
+	return rv
+}
+
+func TestMergeRegisters(t *testing.T) {
+	profile := []ModbusRegister{
+		{Tag: "A", Typ: "u16", Register: 1},
+		{Tag: "B", Typ: "u16", Register: 2},
+		{Tag: "C", Typ: "u16", Register: 3},
+	}
+	tests := []struct {
+		name    string
+		node    []ModbusRegister
+		tags    []string
+		unknown []string
+	}{
+		{"profile only", nil, []string{"A", "B", "C"}, nil},
+		{"override keeps the profile order", []ModbusRegister{{Tag: "B", Offset: 1}}, []string{"A", "B", "C"}, nil},
+		{"skip", []ModbusRegister{{Tag: "A", Skip: true}}, []string{"B", "C"}, nil},
+		{"added after the profile", []ModbusRegister{{Tag: "D", Typ: "s16", Register: 9}, {Tag: "A", Offset: 1}},
+			[]string{"A", "B", "C", "D"}, nil},
+		{"unknown override", []ModbusRegister{{Tag: "b", Offset: 1}, {Tag: "C", Offset: 1}}, []string{"A", "B", "C"}, []string{"b"}},
+		{"unknown skip", []ModbusRegister{{Tag: "E", Skip: true}}, []string{"A", "B", "C"}, []string{"E"}},
+	}
+	for _, tc := range tests {
+		merged, unknown := mergeRegisters(profile, tc.node)
+		if got := tags(merged); !reflect.DeepEqual(got, tc.tags) {
+			t.Errorf("%s: registers %v, expected %v", tc.name, got, tc.tags)
+		}
+		if !reflect.DeepEqual(unknown, tc.unknown) {
+			t.Errorf("%s: unknown %v, expected %v", tc.name, unknown, tc.unknown)
+		}
+	}
+
+	merged, _ := mergeRegisters(profile, []ModbusRegister{{Tag: "B", Offset: 1}})
+	if merged[1].Offset != 1 || merged[1].Register != 2 || merged[1].Typ != "u16" {
+		t.Errorf("override gave %+v", merged[1])
+	}
+	if profile[1].Offset != 0 {
+		t.Error("the profile register was changed")
+	}
+}
+
+func TestApplyModbusProfile(t *testing.T) {
+	node := ModbusNode{
+		Name:     "t300",
+		Profile:  "t300",
+		Interval: 10,
+		Registers: ModbusRegisters{
+			Input: []ModbusRegister{{Tag: "T05", Offset: -98}, {Tag: "T14", Skip: true}},
+		},
+	}
+	if err := applyModbusProfile(&node, "profiles"); err != nil {
+		t.Fatal(err)
+	}
+	if node.Baudrate != 19200 || node.Interval != 10 {
+		t.Errorf("baudrate %d interval %d, expected the profile baudrate and the node interval", node.Baudrate, node.Interval)
+	}
+	if got := tags(node.Registers.Holding); !reflect.DeepEqual(got, []string{"C"}) {
+		t.Errorf("holding %v, expected C", got)
+	}
+	if len(node.Registers.Input) != 1 || node.Registers.Input[0].Offset != -98 || node.Registers.Input[0].Register != 11 {
+		t.Errorf("input %+v, expected T05 with the offset changed", node.Registers.Input)
+	}
+
+	node = ModbusNode{
+		Name:      "t300",
+		Profile:   "t300",
+		Registers: ModbusRegisters{Input: []ModbusRegister{{Tag: "T5", Offset: -98}}, Holding: []ModbusRegister{{Tag: "X", Skip: true}}},
+	}
+	err := applyModbusProfile(&node, "profiles")
+	if err == nil || !strings.Contains(err.Error(), "X, T5") {
+		t.Errorf("error %v, expected the unknown tags to be named", err)
+	}
+
+	// without a profile the node registers are used as given
+	node = ModbusNode{Name: "plain", Registers: ModbusRegisters{Holding: []ModbusRegister{{Tag: "A", Typ: "u16"}}}}
+	if err := applyModbusProfile(&node, "profiles"); err != nil || len(node.Registers.Holding) != 1 {
+		t.Errorf("got %v %+v", err, node.Registers)
+	}
+}
+
+func TestLoadModbusProfile(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "custom.yml")
+	if err := os.WriteFile(fn, []byte("baudrate: 9600\nregisters:\n  holding:\n    - tag: A\n      typ: u16\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := loadModbusProfile(fn, "profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Baudrate != 9600 || len(p.Registers.Holding) != 1 {
+		t.Errorf("loaded %+v", p)
+	}
+	if p, err = loadModbusProfile("T300", "profiles"); err != nil || p.Baudrate != 19200 {
+		t.Errorf("got %+v %v, expected the t300 profile", p, err)
+	}
+	if _, err := loadModbusProfile("missing", dir); err == nil {
+		t.Error("expected an error for a missing profile")
+	}
+	os.WriteFile(filepath.Join(dir, "bad.yaml"), []byte("registers: [\n"), 0644)
+	if _, err := loadModbusProfile("bad", dir); err == nil {
+		t.Error("expected an error for a profile that can not be parsed")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/zathras777/sensors/pkg/zcan"
+)
+
+// rmiParam returns the numeric parameter, which can be given in decimal or
+// as hex with a 0x prefix.
+func rmiParam(params url.Values, name string, def int) (byte, error) {
+	s := params.Get(name)
+	if s == "" {
+		if def < 0 {
+			return 0, fmt.Errorf("%s must be given", name)
+		}
+		return byte(def), nil
+	}
+	v, err := strconv.ParseUint(s, 0, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s'", name, s)
+	}
+	return byte(v), nil
+}
+
+// rmiQuery performs the RMI query described by the parameters, which are
+// node, unit, subunit, prop, type and mode (get, range, step or all). With
+// walk set, every property from "from" to "to" is queried instead of prop.
+func rmiQuery(zc *zcan.ZehnderDevice, params url.Values) (map[string]interface{}, error) {
+	node, err := rmiParam(params, "node", 1)
+	if err != nil {
+		return nil, err
+	}
+	unit, err := rmiParam(params, "unit", -1)
+	if err != nil {
+		return nil, err
+	}
+	subunit, err := rmiParam(params, "subunit", 1)
+	if err != nil {
+		return nil, err
+	}
+	dest := zcan.NewZehnderDestination(node, unit, subunit)
+
+	var flags zcan.ZehnderTypeFlag
+	for _, mode := range strings.Split(params.Get("mode"), ",") {
+		switch strings.ToLower(strings.TrimSpace(mode)) {
+		case "", "get":
+			flags |= zcan.ZehnderRMITypeActualValue
+		case "range":
+			flags |= zcan.ZehnderRMITypeRange
+		case "step":
+			flags |= zcan.ZehnderRMITypeStepSize
+		case "all":
+			flags |= zcan.ZehnderRMITypeActualValue | zcan.ZehnderRMITypeRange | zcan.ZehnderRMITypeStepSize
+		default:
+			return nil, fmt.Errorf("unknown mode '%s'", mode)
+		}
+	}
+
+	var typ *zcan.ZehnderType
+	if params.Get("type") != "" {
+		t, err := zcan.ParseZehnderType(params.Get("type"))
+		if err != nil {
+			return nil, err
+		}
+		typ = &t
+	}
+
+	if walk, _ := strconv.ParseBool(params.Get("walk")); walk {
+		from, err := rmiParam(params, "from", 1)
+		if err != nil {
+			return nil, err
+		}
+		to, err := rmiParam(params, "to", 255)
+		if err != nil {
+			return nil, err
+		}
+		results, err := zc.WalkProperties(dest, from, to, flags, typ)
+		rv := map[string]interface{}{"properties": results}
+		if err != nil {
+			rv["error"] = err.Error()
+		}
+		return rv, nil
+	}
+
+	prop, err := rmiParam(params, "prop", -1)
+	if err != nil {
+		return nil, err
+	}
+	res, err := zc.QueryProperty(dest, prop, flags, typ)
+	var rmiErr zcan.RMIError
+	if err != nil && !errors.As(err, &rmiErr) {
+		return nil, err
+	}
+	return map[string]interface{}{"property": res}, nil
+}
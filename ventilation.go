@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zathras777/sensors/pkg/zcan"
+)
+
+// ventilationAction is a change to the ventilation of a zcan device, either
+// requested over HTTP or from the weekly schedule. Boost, away and bypass
+// last for the number of minutes given, or until changed if 0.
+type ventilationAction struct {
+	Action  string `json:"action"`
+	Value   string `json:"value,omitempty"`
+	Minutes int    `json:"minutes,omitempty"`
+}
+
+func (va ventilationAction) validate() error {
+	switch va.Action {
+	case "speed":
+		_, err := zcan.ParseFanSpeed(va.Value)
+		return err
+	case "boost", "away":
+		if va.Value != "" && va.Value != "on" && va.Value != "off" {
+			return fmt.Errorf("%s must be 'on' or 'off'", va.Action)
+		}
+	case "bypass":
+		if va.Value != "open" && va.Value != "closed" && va.Value != "auto" {
+			return fmt.Errorf("bypass must be 'open', 'closed' or 'auto'")
+		}
+	default:
+		return fmt.Errorf("unknown ventilation action '%s'", va.Action)
+	}
+	if va.Minutes < 0 {
+		return fmt.Errorf("minutes must not be negative")
+	}
+	return nil
+}
+
+func (va ventilationAction) apply(zc *zcan.ZehnderDevice) error {
+	d := time.Duration(va.Minutes) * time.Minute
+	switch va.Action {
+	case "speed":
+		speed, err := zcan.ParseFanSpeed(va.Value)
+		if err != nil {
+			return err
+		}
+		return zc.SetFanSpeed(speed)
+	case "boost":
+		if va.Value == "off" {
+			return zc.CancelBoost()
+		}
+		return zc.SetBoost(d)
+	case "away":
+		if va.Value == "off" {
+			return zc.CancelAway()
+		}
+		return zc.SetAway(d)
+	case "bypass":
+		if va.Value == "auto" {
+			return zc.BypassAuto()
+		}
+		return zc.SetBypass(va.Value == "open", d)
+	}
+	return fmt.Errorf("unknown ventilation action '%s'", va.Action)
+}
+
+func (va ventilationAction) String() string {
+	s := va.Action
+	if va.Value != "" {
+		s += " " + va.Value
+	}
+	if va.Minutes > 0 {
+		s += fmt.Sprintf(" for %d minutes", va.Minutes)
+	}
+	return s
+}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+type scheduleEntry struct {
+	ventilationAction
+	hour, minute int
+	days         [7]bool
+	lastRun      time.Time
+	lastError    string
+}
+
+func newScheduleEntry(cfg ZcanScheduleEntry) (*scheduleEntry, error) {
+	se := scheduleEntry{ventilationAction: ventilationAction{
+		Action:  strings.ToLower(cfg.Action),
+		Value:   strings.ToLower(cfg.Value),
+		Minutes: cfg.Minutes,
+	}}
+	if err := se.validate(); err != nil {
+		return nil, err
+	}
+	at, err := time.Parse("15:04", cfg.At)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time '%s', expected HH:MM", cfg.At)
+	}
+	se.hour, se.minute = at.Hour(), at.Minute()
+
+	if len(cfg.Days) == 0 {
+		for n := range se.days {
+			se.days[n] = true
+		}
+	}
+	for _, day := range cfg.Days {
+		found := false
+		for n, name := range weekdayNames {
+			if strings.HasPrefix(strings.ToLower(day), name) {
+				se.days[n] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown day '%s'", day)
+		}
+	}
+	return &se, nil
+}
+
+// due returns true if the entry should run at the given time.
+func (se *scheduleEntry) due(now time.Time) bool {
+	return se.days[now.Weekday()] && now.Hour() == se.hour && now.Minute() == se.minute &&
+		now.Sub(se.lastRun) > time.Minute
+}
+
+// next returns when the entry will next run after the given time.
+func (se *scheduleEntry) next(now time.Time) time.Time {
+	for n := 0; n <= 7; n++ {
+		day := now.AddDate(0, 0, n)
+		at := time.Date(day.Year(), day.Month(), day.Day(), se.hour, se.minute, 0, 0, now.Location())
+		if se.days[at.Weekday()] && at.After(now) {
+			return at
+		}
+	}
+	return time.Time{}
+}
+
+func (se *scheduleEntry) jsonMap(now time.Time) map[string]interface{} {
+	var days []string
+	for n, on := range se.days {
+		if on {
+			days = append(days, weekdayNames[n])
+		}
+	}
+	rv := map[string]interface{}{
+		"at":     fmt.Sprintf("%02d:%02d", se.hour, se.minute),
+		"days":   days,
+		"action": se.Action,
+		"next":   se.next(now),
+	}
+	if se.Value != "" {
+		rv["value"] = se.Value
+	}
+	if se.Minutes > 0 {
+		rv["minutes"] = se.Minutes
+	}
+	if !se.lastRun.IsZero() {
+		rv["last_run"] = se.lastRun
+	}
+	if se.lastError != "" {
+		rv["last_error"] = se.lastError
+	}
+	return rv
+}
+
+// ventilationControl provides the ventilation endpoints for a zcan device and
+// runs the weekly schedule.
+type ventilationControl struct {
+	zc       *zcan.ZehnderDevice
+	mu       sync.Mutex
+	schedule []*scheduleEntry
+	stopper  chan bool
+}
+
+func newVentilationControl(zc *zcan.ZehnderDevice, entries []ZcanScheduleEntry) *ventilationControl {
+	vc := ventilationControl{zc: zc}
+	for n, cfg := range entries {
+		se, err := newScheduleEntry(cfg)
+		if err != nil {
+			log.Printf("%s: schedule entry %d: %s", zc.Name, n+1, err)
+			continue
+		}
+		vc.schedule = append(vc.schedule, se)
+	}
+	return &vc
+}
+
+func (vc *ventilationControl) Start() {
+	if len(vc.schedule) == 0 || vc.stopper != nil {
+		return
+	}
+	stopper := make(chan bool, 1)
+	vc.stopper = stopper
+	go func() {
+		ticker := time.NewTicker(15 * time.Second)
+	loop:
+		for {
+			select {
+			case now := <-ticker.C:
+				vc.runDue(now)
+			case <-stopper:
+				break loop
+			}
+		}
+		ticker.Stop()
+	}()
+}
+
+func (vc *ventilationControl) Stop() {
+	if vc.stopper == nil {
+		return
+	}
+	vc.stopper <- true
+	vc.stopper = nil
+}
+
+func (vc *ventilationControl) runDue(now time.Time) {
+	vc.mu.Lock()
+	var due []*scheduleEntry
+	for _, se := range vc.schedule {
+		if se.due(now) {
+			se.lastRun = now
+			due = append(due, se)
+		}
+	}
+	vc.mu.Unlock()
+
+	for _, se := range due {
+		err := se.apply(vc.zc)
+		vc.mu.Lock()
+		se.lastError = ""
+		if err != nil {
+			se.lastError = err.Error()
+		}
+		vc.mu.Unlock()
+		if err != nil {
+			log.Printf("%s: scheduled %s failed: %s", vc.zc.Name, se.ventilationAction, err)
+		} else {
+			log.Printf("%s: scheduled %s", vc.zc.Name, se.ventilationAction)
+		}
+	}
+}
+
+func (vc *ventilationControl) JsonResponse() map[string]interface{} {
+	now := time.Now()
+	rv := map[string]interface{}{}
+	for _, slug := range []string{"boost_period_remaining", "bypass_next_change"} {
+		if v, ck := vc.zc.PDOValue(slug); ck {
+			rv[slug] = v
+		}
+	}
+	var entries []map[string]interface{}
+	vc.mu.Lock()
+	for _, se := range vc.schedule {
+		entries = append(entries, se.jsonMap(now))
+	}
+	vc.mu.Unlock()
+	rv["schedule"] = entries
+	return rv
+}
+
+// action handles a POST of action, value and minutes.
+func (vc *ventilationControl) action(params url.Values) (map[string]interface{}, error) {
+	va := ventilationAction{
+		Action: strings.ToLower(params.Get("action")),
+		Value:  strings.ToLower(params.Get("value")),
+	}
+	if s := params.Get("minutes"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("minutes must be a number")
+		}
+		va.Minutes = n
+	}
+	if err := va.validate(); err != nil {
+		return nil, err
+	}
+	if err := va.apply(vc.zc); err != nil {
+		return nil, err
+	}
+	log.Printf("%s: %s", vc.zc.Name, va)
+	return map[string]interface{}{"action": va}, nil
+}
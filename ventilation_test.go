@@ -0,0 +1,149 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zathras777/sensors/pkg/zcan"
+)
+
+func TestNewScheduleEntry(t *testing.T) {
+	all := [7]bool{true, true, true, true, true, true, true}
+	tests := []struct {
+		name string
+		cfg  ZcanScheduleEntry
+		days [7]bool
+		ok   bool
+	}{
+		{"every day", ZcanScheduleEntry{At: "08:00", Action: "speed", Value: "low"}, all, true},
+		{"short names", ZcanScheduleEntry{At: "08:00", Days: []string{"mon", "wed", "fri"}, Action: "speed", Value: "low"},
+			[7]bool{false, true, false, true, false, true, false}, true},
+		{"full names in any case", ZcanScheduleEntry{At: "23:59", Days: []string{"Saturday", "SUNDAY"}, Action: "boost"},
+			[7]bool{true, false, false, false, false, false, true}, true},
+		{"repeated day", ZcanScheduleEntry{At: "00:00", Days: []string{"tue", "Tuesday"}, Action: "away", Value: "off"},
+			[7]bool{false, false, true, false, false, false, false}, true},
+		{"unknown day", ZcanScheduleEntry{At: "08:00", Days: []string{"mon", "funday"}, Action: "boost"}, [7]bool{}, false},
+		{"day too short", ZcanScheduleEntry{At: "08:00", Days: []string{"t"}, Action: "boost"}, [7]bool{}, false},
+		{"empty day", ZcanScheduleEntry{At: "08:00", Days: []string{""}, Action: "boost"}, [7]bool{}, false},
+		{"bad time", ZcanScheduleEntry{At: "8am", Action: "boost"}, [7]bool{}, false},
+		{"hour out of range", ZcanScheduleEntry{At: "24:00", Action: "boost"}, [7]bool{}, false},
+		{"unknown action", ZcanScheduleEntry{At: "08:00", Action: "heat"}, [7]bool{}, false},
+		{"bad speed", ZcanScheduleEntry{At: "08:00", Action: "speed", Value: "turbo"}, [7]bool{}, false},
+		{"negative minutes", ZcanScheduleEntry{At: "08:00", Action: "boost", Minutes: -1}, [7]bool{}, false},
+	}
+	for _, tc := range tests {
+		se, err := newScheduleEntry(tc.cfg)
+		if (err == nil) != tc.ok {
+			t.Errorf("%s: error %v, expected ok %v", tc.name, err, tc.ok)
+			continue
+		}
+		if tc.ok && se.days != tc.days {
+			t.Errorf("%s: days %v, expected %v", tc.name, se.days, tc.days)
+		}
+	}
+}
+
+func TestScheduleDue(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   ZcanScheduleEntry
+		start time.Time
+		ticks int
+		runs  []time.Time
+	}{
+		{
+			name:  "once per minute",
+			cfg:   ZcanScheduleEntry{At: "08:00", Action: "boost", Minutes: 10},
+			start: time.Date(2024, 3, 11, 7, 58, 7, 0, time.UTC),
+			ticks: 16,
+			runs:  []time.Time{time.Date(2024, 3, 11, 8, 0, 7, 0, time.UTC)},
+		},
+		{
+			name:  "tick at the start of the minute",
+			cfg:   ZcanScheduleEntry{At: "08:00", Action: "boost"},
+			start: time.Date(2024, 3, 11, 7, 59, 0, 0, time.UTC),
+			ticks: 12,
+			runs:  []time.Time{time.Date(2024, 3, 11, 8, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:  "again the next day",
+			cfg:   ZcanScheduleEntry{At: "08:00", Action: "boost"},
+			start: time.Date(2024, 3, 11, 7, 59, 50, 0, time.UTC),
+			ticks: 24*60*4 + 8,
+			runs: []time.Time{
+				time.Date(2024, 3, 11, 8, 0, 5, 0, time.UTC),
+				time.Date(2024, 3, 12, 8, 0, 5, 0, time.UTC),
+			},
+		},
+		{
+			name:  "not on other days",
+			cfg:   ZcanScheduleEntry{At: "08:00", Days: []string{"sun"}, Action: "boost"},
+			start: time.Date(2024, 3, 11, 7, 59, 50, 0, time.UTC),
+			ticks: 24 * 60 * 4,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			vc := newVentilationControl(zcan.NewZehnderDevice(1), []ZcanScheduleEntry{tc.cfg})
+			if len(vc.schedule) != 1 {
+				t.Fatal("schedule entry not added")
+			}
+			se := vc.schedule[0]
+			var runs []time.Time
+			// the schedule is checked every 15 seconds
+			for n := 0; n < tc.ticks; n++ {
+				prev := se.lastRun
+				vc.runDue(tc.start.Add(time.Duration(n) * 15 * time.Second))
+				if se.lastRun != prev {
+					runs = append(runs, se.lastRun)
+				}
+			}
+			if len(runs) != len(tc.runs) {
+				t.Fatalf("ran at %v, expected %v", runs, tc.runs)
+			}
+			for n := range runs {
+				if !runs[n].Equal(tc.runs[n]) {
+					t.Errorf("run %d at %v, expected %v", n, runs[n], tc.runs[n])
+				}
+			}
+			// without a connection the action fails and the error is kept
+			if len(runs) > 0 && se.lastError == "" {
+				t.Error("the failed action was not recorded")
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  ZcanScheduleEntry
+		now  time.Time
+		next time.Time
+	}{
+		{"later today", ZcanScheduleEntry{At: "08:00", Action: "boost"},
+			time.Date(2024, 3, 13, 7, 0, 0, 0, time.UTC), time.Date(2024, 3, 13, 8, 0, 0, 0, time.UTC)},
+		{"tomorrow", ZcanScheduleEntry{At: "08:00", Action: "boost"},
+			time.Date(2024, 3, 13, 9, 0, 0, 0, time.UTC), time.Date(2024, 3, 14, 8, 0, 0, 0, time.UTC)},
+		{"at the time runs next", ZcanScheduleEntry{At: "08:00", Action: "boost"},
+			time.Date(2024, 3, 13, 8, 0, 0, 0, time.UTC), time.Date(2024, 3, 14, 8, 0, 0, 0, time.UTC)},
+		{"sunday to monday", ZcanScheduleEntry{At: "06:30", Days: []string{"mon"}, Action: "boost"},
+			time.Date(2024, 3, 17, 22, 0, 0, 0, time.UTC), time.Date(2024, 3, 18, 6, 30, 0, 0, time.UTC)},
+		{"saturday to wednesday", ZcanScheduleEntry{At: "06:30", Days: []string{"wed"}, Action: "boost"},
+			time.Date(2024, 3, 16, 12, 0, 0, 0, time.UTC), time.Date(2024, 3, 20, 6, 30, 0, 0, time.UTC)},
+		{"same day next week", ZcanScheduleEntry{At: "06:30", Days: []string{"wed"}, Action: "boost"},
+			time.Date(2024, 3, 13, 6, 31, 0, 0, time.UTC), time.Date(2024, 3, 20, 6, 30, 0, 0, time.UTC)},
+		{"across the end of the year", ZcanScheduleEntry{At: "00:15", Days: []string{"mon"}, Action: "boost"},
+			time.Date(2024, 12, 31, 23, 0, 0, 0, time.UTC), time.Date(2025, 1, 6, 0, 15, 0, 0, time.UTC)},
+	}
+	for _, tc := range tests {
+		se, err := newScheduleEntry(tc.cfg)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if got := se.next(tc.now); !got.Equal(tc.next) {
+			t.Errorf("%s: next %v, expected %v", tc.name, got, tc.next)
+		}
+	}
+}